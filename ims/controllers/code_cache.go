@@ -0,0 +1,183 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	pr "ims/postgres"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omniful/go_commons/config"
+	"github.com/omniful/go_commons/log"
+	"gorm.io/gorm"
+)
+
+// Read-through cache for SKU and hub lookups. Records are cached as JSON;
+// codes that do not exist are cached as codeCacheMiss for a shorter time so
+// that repeated lookups of an unknown code do not reach Postgres either.
+const (
+	codeCacheMiss       = "__miss__"
+	defaultCodeCacheTTL = 5 * time.Minute
+	defaultMissCacheTTL = 30 * time.Second
+)
+
+const (
+	cacheKindSKU = "sku"
+	cacheKindHub = "hub"
+)
+
+// cacheCounter counts lookups served from Redis and lookups that fell
+// through to Postgres, since process start
+type cacheCounter struct {
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+var cacheCounters = map[string]*cacheCounter{
+	cacheKindSKU: {},
+	cacheKindHub: {},
+}
+
+// Codes are only unique per tenant and seller, so code keys carry both
+func skuCodeKey(tenantID, sellerID, code string) string {
+	return "sku:code:" + tenantID + ":" + sellerID + ":" + code
+}
+
+func hubCodeKey(tenantID, sellerID, code string) string {
+	return "hub:code:" + tenantID + ":" + sellerID + ":" + code
+}
+
+func hubIDKey(id string) string { return "hub:" + id }
+
+func codeCacheTTL(ctx context.Context) (time.Duration, time.Duration) {
+	ttl := config.GetDuration(ctx, "cache.code_ttl")
+	if ttl <= 0 {
+		ttl = defaultCodeCacheTTL
+	}
+	missTTL := config.GetDuration(ctx, "cache.miss_ttl")
+	if missTTL <= 0 {
+		missTTL = defaultMissCacheTTL
+	}
+	return ttl, missTTL
+}
+
+// cachedLookup fills dest from key, calling load on a cache miss. A record
+// known not to exist returns gorm.ErrRecordNotFound without calling load.
+func cachedLookup(ctx context.Context, kind, key string, dest interface{}, load func() error) error {
+	counter := cacheCounters[kind]
+
+	if cached, err := pr.RedisClient.Get(ctx, key); err == nil && cached != "" {
+		if cached == codeCacheMiss {
+			counter.hits.Add(1)
+			return gorm.ErrRecordNotFound
+		}
+		if err := json.Unmarshal([]byte(cached), dest); err == nil {
+			counter.hits.Add(1)
+			return nil
+		}
+	}
+	counter.misses.Add(1)
+
+	err := load()
+	ttl, missTTL := codeCacheTTL(ctx)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		setCache(ctx, key, codeCacheMiss, missTTL)
+	case err == nil:
+		if b, err := json.Marshal(dest); err == nil {
+			setCache(ctx, key, string(b), ttl)
+		}
+	}
+	return err
+}
+
+// cachedLookupMany resolves many codes with one MGET and one query for
+// whatever Redis did not have. It returns the cached JSON of every code that
+// exists; load returns the records it found keyed by code.
+func cachedLookupMany(ctx context.Context, kind string, keyOf func(string) string, codes []string, load func(codes []string) (map[string]interface{}, error)) (map[string][]byte, error) {
+	counter := cacheCounters[kind]
+	found := make(map[string][]byte, len(codes))
+
+	keys := make([]string, len(codes))
+	for i, code := range codes {
+		keys[i] = keyOf(code)
+	}
+
+	var uncached []string
+	values, err := pr.RedisClient.MGet(ctx, keys...)
+	if err != nil || len(values) != len(codes) {
+		if err != nil {
+			log.DefaultLogger().Warnf("Code cache MGET failed: %v", err)
+		}
+		values = make([]interface{}, len(codes))
+	}
+	for i, code := range codes {
+		cached, _ := values[i].(string)
+		switch cached {
+		case "":
+			uncached = append(uncached, code)
+		case codeCacheMiss:
+			counter.hits.Add(1)
+		default:
+			counter.hits.Add(1)
+			found[code] = []byte(cached)
+		}
+	}
+	if len(uncached) == 0 {
+		return found, nil
+	}
+	counter.misses.Add(int64(len(uncached)))
+
+	records, err := load(uncached)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl, missTTL := codeCacheTTL(ctx)
+	for _, code := range uncached {
+		record, ok := records[code]
+		if !ok {
+			setCache(ctx, keyOf(code), codeCacheMiss, missTTL)
+			continue
+		}
+		b, err := json.Marshal(record)
+		if err != nil {
+			continue
+		}
+		found[code] = b
+		setCache(ctx, keyOf(code), string(b), ttl)
+	}
+	return found, nil
+}
+
+// invalidateCache drops keys after a write. A failure is only logged; the
+// entries still expire with their TTL.
+func invalidateCache(ctx context.Context, keys ...string) {
+	if _, err := pr.RedisClient.Del(ctx, keys...); err != nil {
+		log.DefaultLogger().Warnf("Cache invalidation of %v failed: %v", keys, err)
+	}
+}
+
+func setCache(ctx context.Context, key, value string, ttl time.Duration) {
+	if _, err := pr.RedisClient.Set(ctx, key, value, ttl); err != nil {
+		log.DefaultLogger().Warnf("Cache set of %s failed: %v", key, err)
+	}
+}
+
+// GetCacheStats handles GET /cache/stats
+func GetCacheStats(c *gin.Context) {
+	stats := gin.H{}
+	for kind, counter := range cacheCounters {
+		hits, misses := counter.hits.Load(), counter.misses.Load()
+		ratio := 0.0
+		if hits+misses > 0 {
+			ratio = float64(hits) / float64(hits+misses)
+		}
+		stats[kind] = gin.H{"hits": hits, "misses": misses, "hit_ratio": ratio}
+	}
+	c.JSON(http.StatusOK, stats)
+}
@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"ims/model"
+	pr "ims/postgres"
+
+	"github.com/omniful/go_commons/config"
+	"github.com/omniful/go_commons/log"
+	"github.com/omniful/go_commons/pubsub"
+)
+
+// publishInventoryUpdated tells consumers such as the OMS finalizer that more
+// stock is available. Failures are logged and never fail the request.
+func publishInventoryUpdated(ctx context.Context, tenantID, sellerID, hubCode, skuCode string, delta int64, reason string) {
+	if delta <= 0 || pr.KafkaProducer == nil {
+		return
+	}
+
+	event := model.InventoryUpdated{
+		TenantID:   tenantID,
+		SellerID:   sellerID,
+		HubCode:    hubCode,
+		SKUCode:    skuCode,
+		Delta:      delta,
+		Reason:     reason,
+		OccurredAt: time.Now().UTC(),
+	}
+
+	payload, err := pubsub.NewEventInBytes(event)
+	if err != nil {
+		log.DefaultLogger().Errorf("Failed to marshal InventoryUpdated: %v", err)
+		return
+	}
+
+	msg := &pubsub.Message{
+		Topic: config.GetString(ctx, "kafka.inventory_topic"),
+		Key:   tenantID + "|" + hubCode + "|" + skuCode,
+		Value: payload,
+	}
+	if err := pr.KafkaProducer.Publish(ctx, msg); err != nil {
+		log.DefaultLogger().Errorf("Kafka publish %s failed: %v", msg.Topic, err)
+		return
+	}
+	log.Infof("Published %s: %+v", msg.Topic, event)
+}
+
+// inventoryChanged announces a committed change of a hub/SKU's available
+// stock: increases go to Kafka for consumers such as the OMS finalizer, and
+// every change goes to the tenant's inventory.updated webhooks.
+func inventoryChanged(ctx context.Context, tenantID, sellerID, hubCode, skuCode string, delta int64, reason string) {
+	publishInventoryUpdated(ctx, tenantID, sellerID, hubCode, skuCode, delta, reason)
+	if delta == 0 {
+		return
+	}
+	notifyWebhooks(ctx, tenantID, model.EventInventoryUpdated, model.InventoryUpdated{
+		TenantID:   tenantID,
+		SellerID:   sellerID,
+		HubCode:    hubCode,
+		SKUCode:    skuCode,
+		Delta:      delta,
+		Reason:     reason,
+		OccurredAt: time.Now().UTC(),
+	})
+}
+
+// alertStockLevel notifies webhooks when a hub/SKU moved to a worse stock
+// level: inventory.low_stock at its reorder point, inventory.out_of_stock when
+// nothing is available. Staying at a level alerts only once.
+func alertStockLevel(ctx context.Context, before, after model.Inventory) {
+	level := after.StockLevel()
+	if level == model.StockLevelOK || stockLevelRank[level] <= stockLevelRank[before.StockLevel()] {
+		return
+	}
+
+	eventType := model.EventInventoryLowStock
+	if level == model.StockLevelOutOfStock {
+		eventType = model.EventInventoryOutOfStock
+	}
+	log.Infof("Stock alert %s: tenant=%s hub=%s sku=%s available=%d min=%d", eventType, after.TenantID, after.HubCode, after.SKUCode, after.Available, after.MinQuantity)
+
+	notifyWebhooks(ctx, after.TenantID, eventType, model.StockAlert{
+		TenantID:        after.TenantID,
+		SellerID:        after.SellerID,
+		HubCode:         after.HubCode,
+		SKUCode:         after.SKUCode,
+		Level:           level,
+		Quantity:        after.Quantity,
+		Reserved:        after.Reserved,
+		Available:       after.Available,
+		MinQuantity:     after.MinQuantity,
+		ReorderQuantity: after.ReorderQuantity,
+		OccurredAt:      time.Now().UTC(),
+	})
+}
+
+var stockLevelRank = map[string]int{
+	model.StockLevelOK:         0,
+	model.StockLevelLow:        1,
+	model.StockLevelOutOfStock: 2,
+}
+
+// checkStockLevel reloads a hub/SKU whose available stock changed by delta
+// once the change has committed and alerts if it crossed a threshold.
+// Increases never do.
+func checkStockLevel(ctx context.Context, tenantID, sellerID, hubCode, skuCode string, delta int64) {
+	if delta >= 0 {
+		return
+	}
+
+	var after model.Inventory
+	db := pr.DB.GetMasterDB(ctx)
+	if err := db.Where("tenant_id = ? AND seller_id = ? AND hub_code = ? AND sku_code = ?", tenantID, sellerID, hubCode, skuCode).
+		First(&after).Error; err != nil {
+		log.DefaultLogger().Errorf("Stock level check for %s/%s failed: %v", hubCode, skuCode, err)
+		return
+	}
+
+	before := after
+	before.Available -= delta
+	alertStockLevel(ctx, before, after)
+}
@@ -4,13 +4,14 @@ import (
 	"net/http"
 	"time"
 
-	"encoding/json"
+	"ims/middleware"
 	"ims/model"
 	pr "ims/postgres"
 
 	"github.com/gin-gonic/gin"
 	"github.com/omniful/go_commons/i18n"
 	"github.com/omniful/go_commons/log"
+	"gorm.io/gorm"
 )
 
 // CreateHub handles POST /hubs
@@ -26,39 +27,42 @@ func CreateHub(c *gin.Context) {
 	hub.UpdatedAt = now
 
 	db := pr.DB.GetMasterDB(c.Request.Context())
+	if !requireParent(c, db, "error.seller_not_found", &model.Seller{}, "tenant_id = ? AND seller_id = ?", hub.TenantID, hub.SellerID) {
+		return
+	}
+
 	if err := db.Create(&hub).Error; err != nil {
 		log.DefaultLogger().Errorf("CreateHub DB error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.Translate(c, "error.create_hub_failed")})
 		return
 	}
 
+	// the code may be negatively cached from an earlier lookup
+	invalidateCache(c.Request.Context(), hubCodeKey(hub.TenantID, hub.SellerID, hub.HubCode))
+	notifyWebhooks(c.Request.Context(), hub.TenantID, model.EventHubCreated, hub)
+
 	c.JSON(http.StatusCreated, hub)
 }
 
 // GetHub handles GET /hubs/:id
 func GetHub(c *gin.Context) {
 	id := c.Param("id")
-
-	// Try cache first
-	if cached, err := pr.RedisClient.Get(c.Request.Context(), "hub:"+id); err == nil {
-		c.JSON(http.StatusOK, cached)
-		return
-	}
-
 	var hub model.Hub
 
 	db := pr.DB.GetSlaveDB(c.Request.Context())
-	if err := db.First(&hub, "id = ?", id).Error; err != nil {
+	err := cachedLookup(c.Request.Context(), cacheKindHub, hubIDKey(id), &hub, func() error {
+		return db.First(&hub, "id = ?", id).Error
+	})
+	// the id key is shared by every tenant, so a cached hub is checked here
+	if tenantID, ok := middleware.TenantFromContext(c.Request.Context()); err == nil && ok && hub.TenantID != tenantID {
+		err = gorm.ErrRecordNotFound
+	}
+	if err != nil {
 		log.DefaultLogger().Errorf("GetHub DB error: %v", err)
 		c.JSON(http.StatusNotFound, gin.H{"error": i18n.Translate(c, "error.hub_not_found")})
 		return
 	}
 
-	if b, err := json.Marshal(hub); err == nil {
-		// ignore both return values
-		_, _ = pr.RedisClient.Set(c.Request.Context(), "hub:"+id, string(b), 5*time.Minute)
-	}
-
 	c.JSON(http.StatusOK, hub)
 }
 
@@ -73,11 +77,22 @@ func UpdateHub(c *gin.Context) {
 		return
 	}
 
+	oldKey := hubCodeKey(hub.TenantID, hub.SellerID, hub.HubCode)
+	old := hub
 	if err := c.ShouldBindJSON(&hub); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Translate(c, "error.invalid_request")})
 		return
 	}
 
+	if hub.TenantID != old.TenantID || hub.SellerID != old.SellerID {
+		if !requireNoDependents(c, db, hubDependents(old)...) {
+			return
+		}
+		if !requireParent(c, db, "error.seller_not_found", &model.Seller{}, "tenant_id = ? AND seller_id = ?", hub.TenantID, hub.SellerID) {
+			return
+		}
+	}
+
 	hub.UpdatedAt = time.Now().UTC()
 
 	if err := db.Save(&hub).Error; err != nil {
@@ -86,6 +101,9 @@ func UpdateHub(c *gin.Context) {
 		return
 	}
 
+	invalidateCache(c.Request.Context(), hubIDKey(id), oldKey, hubCodeKey(hub.TenantID, hub.SellerID, hub.HubCode))
+	notifyWebhooks(c.Request.Context(), hub.TenantID, model.EventHubUpdated, hub)
+
 	c.JSON(http.StatusOK, hub)
 }
 
@@ -94,12 +112,25 @@ func DeleteHub(c *gin.Context) {
 	id := c.Param("id")
 
 	db := pr.DB.GetMasterDB(c.Request.Context())
-	if err := db.Delete(&model.Hub{}, "id = ?", id).Error; err != nil {
+	var hub model.Hub
+	if err := db.First(&hub, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.Translate(c, "error.hub_not_found")})
+		return
+	}
+
+	if !requireNoDependents(c, db, hubDependents(hub)...) {
+		return
+	}
+
+	if err := db.Delete(&hub).Error; err != nil {
 		log.DefaultLogger().Errorf("DeleteHub DB error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.Translate(c, "error.delete_hub_failed")})
 		return
 	}
 
+	invalidateCache(c.Request.Context(), hubIDKey(id), hubCodeKey(hub.TenantID, hub.SellerID, hub.HubCode))
+	notifyWebhooks(c.Request.Context(), hub.TenantID, model.EventHubDeleted, hub)
+
 	c.Status(http.StatusNoContent)
 }
 
@@ -117,13 +148,22 @@ func ListHubs(c *gin.Context) {
 	c.JSON(http.StatusOK, hubs)
 }
 
-// GetHubByCode handles GET /hubs/code/:hub_code
+// GetHubByCode handles GET /hubs/code/:hub_code?tenant_id=...&seller_id=...
 func GetHubByCode(c *gin.Context) {
 	hubCode := c.Param("hub_code")
-	var hub model.Hub
+	tenantID := c.Query("tenant_id")
+	sellerID := c.Query("seller_id")
 
+	if tenantID == "" || sellerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Translate(c, "error.invalid_request")})
+		return
+	}
+
+	var hub model.Hub
 	db := pr.DB.GetSlaveDB(c.Request.Context())
-	if err := db.Where("hub_code = ?", hubCode).First(&hub).Error; err != nil {
+	if err := cachedLookup(c.Request.Context(), cacheKindHub, hubCodeKey(tenantID, sellerID, hubCode), &hub, func() error {
+		return db.Where("tenant_id = ? AND seller_id = ? AND hub_code = ?", tenantID, sellerID, hubCode).First(&hub).Error
+	}); err != nil {
 		log.DefaultLogger().Errorf("GetHubByCode DB error: %v", err)
 		c.JSON(http.StatusNotFound, gin.H{"error": i18n.Translate(c, "error.hub_not_found")})
 		return
@@ -131,3 +171,13 @@ func GetHubByCode(c *gin.Context) {
 
 	c.JSON(http.StatusOK, hub)
 }
+
+// hubDependents are the rows that keep a hub from being deleted or moved to
+// another seller. A code rename is carried over to inventory and transfers by
+// the foreign keys.
+func hubDependents(hub model.Hub) []dependent {
+	return []dependent{
+		{name: "inventory", model: &model.Inventory{}, query: "tenant_id = ? AND seller_id = ? AND hub_code = ?", args: []interface{}{hub.TenantID, hub.SellerID, hub.HubCode}},
+		{name: "transfers", model: &model.Transfer{}, query: "tenant_id = ? AND seller_id = ? AND (source_hub = ? OR destination_hub = ?)", args: []interface{}{hub.TenantID, hub.SellerID, hub.HubCode, hub.HubCode}},
+	}
+}
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
@@ -8,12 +9,15 @@ import (
 	pr "ims/postgres"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/omniful/go_commons/i18n"
 	"github.com/omniful/go_commons/log"
+	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
-// CreateInventory handles POST /inventory (upsert logic can be added if needed)
+// CreateInventory handles POST /inventory. Each hub/SKU has one row, so
+// creating it twice answers 409; PUT /inventory/upsert creates or changes it.
 func CreateInventory(c *gin.Context) {
 	var inventory model.Inventory
 	if err := c.ShouldBindJSON(&inventory); err != nil {
@@ -22,14 +26,39 @@ func CreateInventory(c *gin.Context) {
 	}
 
 	inventory.UpdatedAt = time.Now().UTC()
+	// reserved is only ever changed through reservations
+	inventory.Reserved = 0
 
 	db := pr.DB.GetMasterDB(c.Request.Context())
-	if err := db.Create(&inventory).Error; err != nil {
+	if !requireInventoryParents(c, db, inventory) {
+		return
+	}
+
+	errExists := errors.New("inventory exists")
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&inventory)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errExists
+		}
+		return recordMovement(tx, inventory, inventory.Quantity, model.MovementRestock, "")
+	})
+	switch {
+	case errors.Is(err, errExists):
+		// the hub/SKU already has a row; PUT /inventory/upsert changes it
+		c.JSON(http.StatusConflict, gin.H{"error": i18n.Translate(c, "error.inventory_exists")})
+		return
+	case err != nil:
 		log.DefaultLogger().Errorf("CreateInventory DB error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.Translate(c, "error.create_inventory_failed")})
 		return
 	}
 
+	inventory.Available = inventory.Quantity
+	inventoryChanged(c.Request.Context(), inventory.TenantID, inventory.SellerID, inventory.HubCode, inventory.SKUCode, inventory.Quantity, model.InventoryReasonCreated)
+
 	c.JSON(http.StatusCreated, inventory)
 }
 
@@ -48,44 +77,150 @@ func GetInventory(c *gin.Context) {
 	c.JSON(http.StatusOK, inventory)
 }
 
-// UpdateInventory handles PUT /inventory/:id
+// UpdateInventory handles PUT /inventory/:id. The row is locked while the
+// body is applied, and reserved is never written here: it is only ever
+// changed through reservations.
 func UpdateInventory(c *gin.Context) {
 	id := c.Param("id")
-	var inventory model.Inventory
+	var inventory, old model.Inventory
+	var moved bool
+
+	errNotFound := errors.New("inventory not found")
+	errInvalid := errors.New("invalid request")
+	errBelowReserved := errors.New("quantity below reserved")
+	errAnswered := errors.New("response already written")
 
 	db := pr.DB.GetMasterDB(c.Request.Context())
-	if err := db.First(&inventory, "id = ?", id).Error; err != nil {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&inventory, "id = ?", id).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		old = inventory
+		if err := c.ShouldBindBodyWith(&inventory, binding.JSON); err != nil {
+			return errInvalid
+		}
+		inventory.ID = old.ID
+		inventory.Reserved = old.Reserved
+		if inventory.Quantity < inventory.Reserved {
+			return errBelowReserved
+		}
+
+		moved = inventory.TenantID != old.TenantID || inventory.SellerID != old.SellerID ||
+			inventory.HubCode != old.HubCode || inventory.SKUCode != old.SKUCode
+		if moved && old.Reserved > 0 {
+			// the reservations holding it settle against the old hub/SKU
+			return errInventoryReserved
+		}
+		if moved && !requireInventoryParents(c, tx, inventory) {
+			return errAnswered
+		}
+
+		inventory.UpdatedAt = time.Now().UTC()
+		updates := map[string]interface{}{
+			"quantity":         inventory.Quantity,
+			"min_quantity":     inventory.MinQuantity,
+			"reorder_quantity": inventory.ReorderQuantity,
+			"updated_at":       inventory.UpdatedAt,
+		}
+		if moved {
+			updates["tenant_id"] = inventory.TenantID
+			updates["seller_id"] = inventory.SellerID
+			updates["hub_code"] = inventory.HubCode
+			updates["sku_code"] = inventory.SKUCode
+		}
+		if err := tx.Model(&model.Inventory{}).Where("id = ?", inventory.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		if !moved {
+			return recordMovement(tx, inventory, inventory.Quantity-old.Quantity, model.MovementAdjustment, "")
+		}
+		// the stock leaves the old hub/SKU and appears under the new one
+		emptied := old
+		emptied.Quantity = 0
+		if err := recordMovement(tx, emptied, -old.Quantity, model.MovementAdjustment, ""); err != nil {
+			return err
+		}
+		return recordMovement(tx, inventory, inventory.Quantity, model.MovementAdjustment, "")
+	})
+	switch {
+	case errors.Is(err, errAnswered):
+		return
+	case errors.Is(err, errNotFound):
 		c.JSON(http.StatusNotFound, gin.H{"error": i18n.Translate(c, "error.inventory_not_found")})
 		return
-	}
-
-	if err := c.ShouldBindJSON(&inventory); err != nil {
+	case errors.Is(err, errInvalid):
 		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Translate(c, "error.invalid_request")})
 		return
-	}
-
-	inventory.UpdatedAt = time.Now().UTC()
-
-	if err := db.Save(&inventory).Error; err != nil {
+	case errors.Is(err, errInventoryReserved):
+		c.JSON(http.StatusConflict, gin.H{"error": i18n.Translate(c, "error.inventory_reserved"), "reserved": old.Reserved})
+		return
+	case errors.Is(err, errBelowReserved):
+		c.JSON(http.StatusConflict, gin.H{"error": i18n.Translate(c, "error.quantity_below_reserved")})
+		return
+	case isUniqueViolation(err):
+		// moved onto a hub/SKU that already has a row
+		c.JSON(http.StatusConflict, gin.H{"error": i18n.Translate(c, "error.inventory_exists")})
+		return
+	case err != nil:
 		log.DefaultLogger().Errorf("UpdateInventory DB error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.Translate(c, "error.update_inventory_failed")})
 		return
 	}
 
+	inventory.Available = inventory.Quantity - inventory.Reserved
+	inventoryChanged(c.Request.Context(), inventory.TenantID, inventory.SellerID, inventory.HubCode, inventory.SKUCode, inventory.Available-old.Available, model.InventoryReasonUpdated)
+	alertStockLevel(c.Request.Context(), old, inventory)
+
 	c.JSON(http.StatusOK, inventory)
 }
 
-// DeleteInventory handles DELETE /inventory/:id
+// DeleteInventory handles DELETE /inventory/:id. Stock still on hand is
+// written off in the ledger; a row with reserved stock cannot be deleted.
 func DeleteInventory(c *gin.Context) {
 	id := c.Param("id")
 
+	var inventory model.Inventory
 	db := pr.DB.GetMasterDB(c.Request.Context())
-	if err := db.Delete(&model.Inventory{}, "id = ?", id).Error; err != nil {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&inventory, "id = ?", id).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if inventory.Reserved > 0 {
+			// open reservations still hold part of the stock
+			return errInventoryReserved
+		}
+		if err := tx.Delete(&model.Inventory{}, "id = ?", inventory.ID).Error; err != nil {
+			return err
+		}
+		delta := -inventory.Quantity
+		inventory.Quantity = 0
+		return recordMovement(tx, inventory, delta, model.MovementAdjustment, "")
+	})
+	if errors.Is(err, errInventoryReserved) {
+		c.JSON(http.StatusConflict, gin.H{"error": i18n.Translate(c, "error.inventory_reserved"), "reserved": inventory.Reserved})
+		return
+	}
+	if err != nil {
 		log.DefaultLogger().Errorf("DeleteInventory DB error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.Translate(c, "error.delete_inventory_failed")})
 		return
 	}
 
+	if inventory.ID != 0 {
+		// Available still holds what the row had before it went
+		inventoryChanged(c.Request.Context(), inventory.TenantID, inventory.SellerID, inventory.HubCode, inventory.SKUCode, -inventory.Available, model.InventoryReasonDeleted)
+	}
+
 	c.Status(http.StatusNoContent)
 }
 
@@ -126,6 +261,31 @@ func QueryInventory(c *gin.Context) {
 	c.JSON(http.StatusOK, inventory)
 }
 
+// GetInventoryAvailability handles GET /inventory/availability?tenant_id=&seller_id=&sku_code=...
+// It returns the stock of the given SKUs at every hub of the seller, for
+// callers such as the OMS finalizer that choose between hubs.
+func GetInventoryAvailability(c *gin.Context) {
+	tenantID := c.Query("tenant_id")
+	sellerID := c.Query("seller_id")
+	skuCodes := c.QueryArray("sku_code")
+	if tenantID == "" || sellerID == "" || len(skuCodes) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Translate(c, "error.invalid_request")})
+		return
+	}
+
+	inventories := []model.Inventory{}
+	db := pr.DB.GetSlaveDB(c.Request.Context())
+	if err := db.Where("tenant_id = ? AND seller_id = ? AND sku_code IN ?", tenantID, sellerID, skuCodes).
+		Order("hub_code, sku_code").
+		Find(&inventories).Error; err != nil {
+		log.DefaultLogger().Errorf("GetInventoryAvailability DB error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.Translate(c, "error.list_inventory_failed")})
+		return
+	}
+
+	c.JSON(http.StatusOK, inventories)
+}
+
 // ConsumeInventory handles POST /inventory/consume
 func ConsumeInventory(c *gin.Context) {
 	var req struct {
@@ -134,6 +294,8 @@ func ConsumeInventory(c *gin.Context) {
 		HubCode  string `json:"hub_code"`
 		SKUCode  string `json:"sku_code"`
 		Quantity int64  `json:"quantity"`
+		// e.g. the OMS order id, recorded in the inventory ledger
+		ReferenceID string `json:"reference_id"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
@@ -142,37 +304,61 @@ func ConsumeInventory(c *gin.Context) {
 
 	db := pr.DB.GetMasterDB(c.Request.Context())
 
-	var inventory model.Inventory
-	// Lock row for update
-	if err := db.Clauses(clause.Locking{Strength: "UPDATE"}).
-		Where("tenant_id = ? AND seller_id = ? AND hub_code = ? AND sku_code = ?", req.TenantID, req.SellerID, req.HubCode, req.SKUCode).
-		First(&inventory).Error; err != nil {
+	var inventory, before model.Inventory
+	var newQty int64
+	errNotFound := errors.New("inventory not found")
+
+	// Lock and decrement inside one transaction so the row lock is held until commit
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("tenant_id = ? AND seller_id = ? AND hub_code = ? AND sku_code = ?", req.TenantID, req.SellerID, req.HubCode, req.SKUCode).
+			First(&inventory).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errNotFound
+			}
+			return err
+		}
+
+		log.Infof("🔍 Fetched inventory before update: %+v", inventory)
+		before = inventory
+
+		if inventory.Available < req.Quantity {
+			return errInsufficientInventory
+		}
+
+		newQty = inventory.Quantity - req.Quantity
+		updatedAt := time.Now().UTC()
+
+		// Use Updates instead of Save for reliability
+		if err := tx.Model(&inventory).
+			Where("id = ?", inventory.ID).
+			Updates(map[string]interface{}{
+				"quantity":   newQty,
+				"updated_at": updatedAt,
+			}).Error; err != nil {
+			return err
+		}
+
+		inventory.Quantity = newQty
+		return recordMovement(tx, inventory, -req.Quantity, model.MovementOrderConsume, req.ReferenceID)
+	})
+
+	switch {
+	case errors.Is(err, errNotFound):
 		c.JSON(http.StatusNotFound, gin.H{"error": "Inventory not found"})
 		return
-	}
-
-	log.Infof("🔍 Fetched inventory before update: %+v", inventory)
-
-	if inventory.Quantity < req.Quantity {
+	case errors.Is(err, errInsufficientInventory):
 		c.JSON(http.StatusConflict, gin.H{"error": "Insufficient inventory"})
 		return
-	}
-
-	newQty := inventory.Quantity - req.Quantity
-	updatedAt := time.Now().UTC()
-
-	// Use Updates instead of Save for reliability
-	if err := db.Model(&inventory).
-		Where("id = ?", inventory.ID).
-		Updates(map[string]interface{}{
-			"quantity":   newQty,
-			"updated_at": updatedAt,
-		}).Error; err != nil {
+	case err != nil:
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update inventory"})
 		return
 	}
 
 	log.Infof(" Inventory updated: ID=%d New Quantity=%d", inventory.ID, newQty)
+	inventory.Available = inventory.Quantity - inventory.Reserved
+	inventoryChanged(c.Request.Context(), inventory.TenantID, inventory.SellerID, inventory.HubCode, inventory.SKUCode, -req.Quantity, model.InventoryReasonConsumed)
+	alertStockLevel(c.Request.Context(), before, inventory)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message":   "Inventory consumed",
@@ -0,0 +1,493 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"ims/model"
+	pr "ims/postgres"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omniful/go_commons/config"
+	"github.com/omniful/go_commons/i18n"
+	"github.com/omniful/go_commons/log"
+	"gorm.io/gorm"
+)
+
+// Transaction modes of POST /inventory/bulk
+const (
+	BulkTxChunked = "chunked" // each chunk commits on its own; bad rows are skipped
+	BulkTxSingle  = "single"  // every row is applied or none is
+)
+
+// Row statuses in the bulk report
+const (
+	bulkRowCreated    = "created"
+	bulkRowUpdated    = "updated"
+	bulkRowUnchanged  = "unchanged"
+	bulkRowFailed     = "failed"
+	bulkRowRolledBack = "rolled_back" // valid, but undone with the rest of its transaction
+)
+
+const (
+	defaultBulkMaxRows   = 10000
+	defaultBulkChunkSize = 500
+)
+
+// BulkInventoryRow is one hub/SKU quantity of POST /inventory/bulk
+type BulkInventoryRow struct {
+	HubCode  string `json:"hub_code"`
+	SKUCode  string `json:"sku_code"`
+	Quantity int64  `json:"quantity"`
+}
+
+// BulkInventoryResult reports what happened to one row, or on a dry run what
+// would happen
+type BulkInventoryResult struct {
+	Row         int    `json:"row"` // 1-based position in the array, or CSV line after the header
+	HubCode     string `json:"hub_code"`
+	SKUCode     string `json:"sku_code"`
+	Status      string `json:"status"`
+	OldQuantity *int64 `json:"old_quantity,omitempty"`
+	NewQuantity *int64 `json:"new_quantity,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// BulkInventoryResponse is the report of POST /inventory/bulk
+type BulkInventoryResponse struct {
+	DryRun      bool                  `json:"dry_run"`
+	Mode        string                `json:"mode"`
+	Transaction string                `json:"transaction"`
+	Summary     map[string]int        `json:"summary"`
+	Rows        []BulkInventoryResult `json:"rows"`
+}
+
+// bulkInput is a parsed row, or the reason it could not be parsed
+type bulkInput struct {
+	BulkInventoryRow
+	parseErr string
+}
+
+// invKey identifies an inventory row within one tenant and seller
+type invKey struct{ hub, sku string }
+
+// invState is the quantity and reservation of an inventory row during a dry run
+type invState struct {
+	exists   bool
+	quantity int64
+	reserved int64
+}
+
+// bulkUpsert carries one POST /inventory/bulk request through its chunks
+type bulkUpsert struct {
+	tenantID string
+	sellerID string
+	mode     string
+	dryRun   bool
+	now      time.Time
+	// dry-run view of every row touched so far, so repeated hub/SKU rows
+	// build on each other as they would when applied
+	state map[invKey]*invState
+}
+
+// pendingEvent is a stock change whose inventory.updated event and stock
+// alert go out once its chunk commits
+type pendingEvent struct {
+	hub, sku, reason string
+	delta            int64
+}
+
+var errBulkRowsFailed = errors.New("bulk rows failed")
+
+// BulkUpsertInventory handles POST /inventory/bulk?tenant_id=&seller_id=&mode=&transaction=&dry_run=.
+// The body is a JSON array of {hub_code, sku_code, quantity} or a multipart
+// upload ("file") of a CSV with those columns. Rows are upserted in set or
+// delta mode as in PUT /inventory/upsert, in chunks that commit separately
+// (transaction=chunked, the default) or in one transaction (single). With
+// dry_run=true nothing is written and the report shows the diff.
+func BulkUpsertInventory(c *gin.Context) {
+	job := &bulkUpsert{
+		tenantID: c.Query("tenant_id"),
+		sellerID: c.Query("seller_id"),
+		mode:     c.DefaultQuery("mode", UpsertModeSet),
+		dryRun:   c.Query("dry_run") == "true",
+		now:      time.Now().UTC(),
+		state:    make(map[invKey]*invState),
+	}
+	txMode := c.DefaultQuery("transaction", BulkTxChunked)
+	if job.tenantID == "" || job.sellerID == "" ||
+		(job.mode != UpsertModeSet && job.mode != UpsertModeDelta) ||
+		(txMode != BulkTxChunked && txMode != BulkTxSingle) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Translate(c, "error.invalid_request")})
+		return
+	}
+
+	inputs, err := readBulkInventoryRows(c)
+	if err != nil {
+		log.DefaultLogger().Warnf("BulkUpsertInventory bad body: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Translate(c, "error.invalid_request")})
+		return
+	}
+	maxRows := bulkMaxRows(c)
+	if len(inputs) == 0 || len(inputs) > maxRows {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Translate(c, "error.bulk_row_count"), "max_rows": maxRows})
+		return
+	}
+
+	chunkSize := config.GetInt(c, "inventory.bulk_chunk_size")
+	if chunkSize <= 0 {
+		chunkSize = defaultBulkChunkSize
+	}
+	if txMode == BulkTxSingle {
+		chunkSize = len(inputs)
+	}
+
+	ctx := c.Request.Context()
+	db := pr.DB.GetMasterDB(ctx)
+	results := make([]BulkInventoryResult, len(inputs))
+	for i, in := range inputs {
+		results[i] = BulkInventoryResult{Row: i + 1, HubCode: in.HubCode, SKUCode: in.SKUCode}
+	}
+
+	for start := 0; start < len(inputs); start += chunkSize {
+		end := start + chunkSize
+		if end > len(inputs) {
+			end = len(inputs)
+		}
+		chunk, chunkResults := inputs[start:end], results[start:end]
+
+		var events []pendingEvent
+		if job.dryRun {
+			err = job.simulate(db, chunk, chunkResults)
+			if err == nil && txMode == BulkTxSingle && countStatus(chunkResults, bulkRowFailed) > 0 {
+				err = errBulkRowsFailed
+			}
+		} else {
+			err = db.Transaction(func(tx *gorm.DB) error {
+				var err error
+				events, err = job.apply(tx, chunk, chunkResults)
+				if err == nil && txMode == BulkTxSingle && countStatus(chunkResults, bulkRowFailed) > 0 {
+					return errBulkRowsFailed
+				}
+				return err
+			})
+		}
+
+		switch {
+		case errors.Is(err, errBulkRowsFailed):
+			markRolledBack(chunkResults, "")
+		case err != nil:
+			log.DefaultLogger().Errorf("BulkUpsertInventory chunk at row %d DB error: %v", start+1, err)
+			markRolledBack(chunkResults, "chunk could not be applied")
+		default:
+			for _, e := range events {
+				inventoryChanged(ctx, job.tenantID, job.sellerID, e.hub, e.sku, e.delta, e.reason)
+				checkStockLevel(ctx, job.tenantID, job.sellerID, e.hub, e.sku, e.delta)
+			}
+		}
+	}
+
+	resp := BulkInventoryResponse{
+		DryRun:      job.dryRun,
+		Mode:        job.mode,
+		Transaction: txMode,
+		Summary:     map[string]int{"total": len(results)},
+		Rows:        results,
+	}
+	for _, r := range results {
+		resp.Summary[r.Status]++
+	}
+
+	status := http.StatusOK
+	if txMode == BulkTxSingle && resp.Summary[bulkRowFailed] > 0 {
+		status = http.StatusUnprocessableEntity
+	}
+	c.JSON(status, resp)
+}
+
+// bulkMaxRows is the most rows one bulk or reconciliation request may carry
+func bulkMaxRows(c *gin.Context) int {
+	if n := config.GetInt(c, "inventory.bulk_max_rows"); n > 0 {
+		return n
+	}
+	return defaultBulkMaxRows
+}
+
+// readBulkInventoryRows parses the JSON array or uploaded CSV of the request
+func readBulkInventoryRows(c *gin.Context) ([]bulkInput, error) {
+	if strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+		fh, err := c.FormFile("file")
+		if err != nil {
+			return nil, err
+		}
+		f, err := fh.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return parseBulkInventoryCSV(f)
+	}
+
+	var rows []BulkInventoryRow
+	if err := c.ShouldBindJSON(&rows); err != nil {
+		return nil, err
+	}
+	inputs := make([]bulkInput, len(rows))
+	for i, r := range rows {
+		inputs[i] = bulkInput{BulkInventoryRow: r}
+	}
+	return inputs, nil
+}
+
+// parseBulkInventoryCSV reads hub_code,sku_code,quantity rows, in any column
+// order. A row that cannot be parsed is kept with its error for the report.
+func parseBulkInventoryCSV(r io.Reader) ([]bulkInput, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	idx := make(map[string]int, len(header))
+	for i, col := range header {
+		idx[strings.TrimSpace(col)] = i
+	}
+	for _, col := range []string{"hub_code", "sku_code", "quantity"} {
+		if _, ok := idx[col]; !ok {
+			return nil, fmt.Errorf("missing column %s", col)
+		}
+	}
+
+	var inputs []bulkInput
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return inputs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		get := func(col string) string {
+			if i := idx[col]; i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		in := bulkInput{BulkInventoryRow: BulkInventoryRow{HubCode: get("hub_code"), SKUCode: get("sku_code")}}
+		qty, err := strconv.ParseInt(get("quantity"), 10, 64)
+		if err != nil {
+			in.parseErr = fmt.Sprintf("quantity %q is not an integer", get("quantity"))
+		}
+		in.Quantity = qty
+		inputs = append(inputs, in)
+	}
+}
+
+// validate fills in the results of rows that cannot be applied and returns
+// whether any row is left to apply
+func (j *bulkUpsert) validate(db *gorm.DB, chunk []bulkInput, results []BulkInventoryResult) (bool, error) {
+	var hubCodes, skuCodes []string
+	for _, in := range chunk {
+		hubCodes = append(hubCodes, in.HubCode)
+		skuCodes = append(skuCodes, in.SKUCode)
+	}
+
+	knownHubs, knownSKUs, err := knownCodes(db, j.tenantID, j.sellerID, hubCodes, skuCodes)
+	if err != nil {
+		return false, err
+	}
+
+	left := false
+	for i, in := range chunk {
+		switch {
+		case in.parseErr != "":
+			results[i].fail(in.parseErr)
+		case in.HubCode == "" || in.SKUCode == "":
+			results[i].fail("hub_code and sku_code are required")
+		case !knownHubs[in.HubCode]:
+			results[i].fail(fmt.Sprintf("hub %s does not exist", in.HubCode))
+		case !knownSKUs[in.SKUCode]:
+			results[i].fail(fmt.Sprintf("SKU %s does not exist", in.SKUCode))
+		case j.mode == UpsertModeSet && in.Quantity < 0:
+			results[i].fail("quantity cannot be negative")
+		default:
+			left = true
+		}
+	}
+	return left, nil
+}
+
+// knownCodes returns which of hubCodes and skuCodes exist for the tenant and seller
+func knownCodes(db *gorm.DB, tenantID, sellerID string, hubCodes, skuCodes []string) (map[string]bool, map[string]bool, error) {
+	var hubs, skus []string
+	if err := db.Model(&model.Hub{}).Where("tenant_id = ? AND seller_id = ? AND hub_code IN ?", tenantID, sellerID, hubCodes).
+		Pluck("hub_code", &hubs).Error; err != nil {
+		return nil, nil, err
+	}
+	if err := db.Model(&model.SKU{}).Where("tenant_id = ? AND seller_id = ? AND sku_code IN ?", tenantID, sellerID, skuCodes).
+		Pluck("sku_code", &skus).Error; err != nil {
+		return nil, nil, err
+	}
+	return toSet(hubs), toSet(skus), nil
+}
+
+// apply upserts the valid rows of a chunk inside tx and returns the events to
+// publish once it commits
+func (j *bulkUpsert) apply(tx *gorm.DB, chunk []bulkInput, results []BulkInventoryResult) ([]pendingEvent, error) {
+	left, err := j.validate(tx, chunk, results)
+	if err != nil || !left {
+		return nil, err
+	}
+
+	var events []pendingEvent
+	for i, in := range chunk {
+		if results[i].Status == bulkRowFailed {
+			continue
+		}
+
+		row, err := upsertInventory(tx, UpsertInventoryRequest{
+			TenantID: j.tenantID,
+			SellerID: j.sellerID,
+			HubCode:  in.HubCode,
+			SKUCode:  in.SKUCode,
+			Quantity: in.Quantity,
+			Mode:     j.mode,
+		}, j.now)
+		switch {
+		case errors.Is(err, errUpsertMissing):
+			results[i].fail("inventory does not exist, a negative delta cannot create it")
+			continue
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			results[i].fail("quantity would drop below reserved stock")
+			continue
+		case err != nil:
+			return nil, err
+		}
+
+		results[i].record(row.OldQuantity, row.Quantity, row.Inserted)
+		if delta := row.Quantity - row.OldQuantity; delta != 0 {
+			reason := model.InventoryReasonUpdated
+			if row.Inserted {
+				reason = model.InventoryReasonCreated
+			}
+			events = append(events, pendingEvent{hub: in.HubCode, sku: in.SKUCode, delta: delta, reason: reason})
+		}
+	}
+	return events, nil
+}
+
+// simulate fills in the results a chunk would get, without writing anything
+func (j *bulkUpsert) simulate(db *gorm.DB, chunk []bulkInput, results []BulkInventoryResult) error {
+	left, err := j.validate(db, chunk, results)
+	if err != nil || !left {
+		return err
+	}
+
+	var hubCodes, skuCodes []string
+	for i, in := range chunk {
+		if results[i].Status != bulkRowFailed && j.state[invKey{in.HubCode, in.SKUCode}] == nil {
+			hubCodes = append(hubCodes, in.HubCode)
+			skuCodes = append(skuCodes, in.SKUCode)
+		}
+	}
+	if len(hubCodes) > 0 {
+		var existing []model.Inventory
+		if err := db.Where("tenant_id = ? AND seller_id = ? AND hub_code IN ? AND sku_code IN ?", j.tenantID, j.sellerID, hubCodes, skuCodes).
+			Find(&existing).Error; err != nil {
+			return err
+		}
+		for _, inv := range existing {
+			key := invKey{inv.HubCode, inv.SKUCode}
+			if j.state[key] == nil {
+				j.state[key] = &invState{exists: true, quantity: inv.Quantity, reserved: inv.Reserved}
+			}
+		}
+	}
+
+	for i, in := range chunk {
+		if results[i].Status == bulkRowFailed {
+			continue
+		}
+		key := invKey{in.HubCode, in.SKUCode}
+		st := j.state[key]
+		if st == nil {
+			st = &invState{}
+			j.state[key] = st
+		}
+
+		newQty := in.Quantity
+		if j.mode == UpsertModeDelta {
+			newQty = st.quantity + in.Quantity
+		}
+		switch {
+		case !st.exists && newQty < 0:
+			results[i].fail("inventory does not exist, a negative delta cannot create it")
+			continue
+		case newQty < st.reserved:
+			results[i].fail("quantity would drop below reserved stock")
+			continue
+		}
+
+		results[i].record(st.quantity, newQty, !st.exists)
+		st.exists, st.quantity = true, newQty
+	}
+	return nil
+}
+
+func (r *BulkInventoryResult) fail(msg string) {
+	r.Status = bulkRowFailed
+	r.Error = msg
+}
+
+func (r *BulkInventoryResult) record(oldQty, newQty int64, created bool) {
+	r.OldQuantity, r.NewQuantity = &oldQty, &newQty
+	switch {
+	case created:
+		r.Status = bulkRowCreated
+		r.OldQuantity = nil
+	case oldQty == newQty:
+		r.Status = bulkRowUnchanged
+	default:
+		r.Status = bulkRowUpdated
+	}
+}
+
+// markRolledBack reports every row of a chunk that did not fail on its own as
+// rolled back, with msg when the whole chunk failed
+func markRolledBack(results []BulkInventoryResult, msg string) {
+	for i := range results {
+		if results[i].Status == bulkRowFailed {
+			continue
+		}
+		results[i].Status = bulkRowRolledBack
+		results[i].Error = msg
+		results[i].OldQuantity, results[i].NewQuantity = nil, nil
+	}
+}
+
+func countStatus(results []BulkInventoryResult, status string) int {
+	n := 0
+	for _, r := range results {
+		if r.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+func toSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"net/http"
+
+	"ims/model"
+	pr "ims/postgres"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omniful/go_commons/i18n"
+	"github.com/omniful/go_commons/log"
+)
+
+// LowStockItem is an inventory row at or below its reorder point
+type LowStockItem struct {
+	model.Inventory
+	Level string `json:"level"` // low_stock or out_of_stock
+}
+
+// GetLowStockInventory handles GET /inventory/low-stock?tenant_id=&seller_id=.
+// It lists every hub/SKU whose available stock is at or below min_quantity,
+// which with no threshold set means out of stock. hub_code, sku_code and
+// level (low_stock or out_of_stock) narrow the report.
+func GetLowStockInventory(c *gin.Context) {
+	tenantID := c.Query("tenant_id")
+	sellerID := c.Query("seller_id")
+	level := c.Query("level")
+	if tenantID == "" || sellerID == "" ||
+		(level != "" && level != model.StockLevelLow && level != model.StockLevelOutOfStock) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Translate(c, "error.invalid_request")})
+		return
+	}
+
+	db := pr.DB.GetSlaveDB(c.Request.Context())
+	q := db.Where("tenant_id = ? AND seller_id = ? AND quantity - reserved <= min_quantity", tenantID, sellerID)
+	if hubCode := c.Query("hub_code"); hubCode != "" {
+		q = q.Where("hub_code = ?", hubCode)
+	}
+	if skuCode := c.Query("sku_code"); skuCode != "" {
+		q = q.Where("sku_code = ?", skuCode)
+	}
+	switch level {
+	case model.StockLevelLow:
+		q = q.Where("quantity - reserved > 0")
+	case model.StockLevelOutOfStock:
+		q = q.Where("quantity - reserved <= 0")
+	}
+
+	var rows []model.Inventory
+	if err := q.Order("hub_code, sku_code").Find(&rows).Error; err != nil {
+		log.DefaultLogger().Errorf("GetLowStockInventory DB error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.Translate(c, "error.list_inventory_failed")})
+		return
+	}
+
+	items := make([]LowStockItem, 0, len(rows))
+	for _, r := range rows {
+		items = append(items, LowStockItem{Inventory: r, Level: r.StockLevel()})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tenant_id": tenantID,
+		"seller_id": sellerID,
+		"items":     items,
+	})
+}
@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"ims/middleware"
+	"ims/model"
+	pr "ims/postgres"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omniful/go_commons/i18n"
+	"github.com/omniful/go_commons/log"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultMovementsLimit = 100
+	maxMovementsLimit     = 1000
+)
+
+// recordMovement appends a ledger entry for a change of delta to the on-hand
+// quantity of inv, which already holds the quantity after the change. It must
+// run in the transaction that made the change. The actor comes from the
+// statement's context.
+func recordMovement(tx *gorm.DB, inv model.Inventory, delta int64, reason, referenceID string) error {
+	if delta == 0 {
+		return nil
+	}
+	movement := model.InventoryMovement{
+		TenantID:    inv.TenantID,
+		SellerID:    inv.SellerID,
+		HubCode:     inv.HubCode,
+		SKUCode:     inv.SKUCode,
+		Delta:       delta,
+		Balance:     inv.Quantity,
+		Reason:      reason,
+		ReferenceID: referenceID,
+		Actor:       middleware.ActorFromContext(tx.Statement.Context),
+	}
+	return tx.Create(&movement).Error
+}
+
+// ListInventoryMovements handles GET /inventory/movements?tenant_id=&seller_id=&hub_code=&sku_code=.
+// At least one of hub_code and sku_code is required. reason, reference_id,
+// from and to (RFC 3339) narrow the result. Movements come newest first,
+// limit at a time; pass next_before_id as before_id for the next page.
+func ListInventoryMovements(c *gin.Context) {
+	tenantID := c.Query("tenant_id")
+	sellerID := c.Query("seller_id")
+	hubCode := c.Query("hub_code")
+	skuCode := c.Query("sku_code")
+	if tenantID == "" || sellerID == "" || (hubCode == "" && skuCode == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Translate(c, "error.invalid_request")})
+		return
+	}
+
+	db := pr.DB.GetSlaveDB(c.Request.Context())
+	q := db.Where("tenant_id = ? AND seller_id = ?", tenantID, sellerID)
+	if hubCode != "" {
+		q = q.Where("hub_code = ?", hubCode)
+	}
+	if skuCode != "" {
+		q = q.Where("sku_code = ?", skuCode)
+	}
+	if reason := c.Query("reason"); reason != "" {
+		if !model.ValidMovementReason(reason) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Translate(c, "error.invalid_request")})
+			return
+		}
+		q = q.Where("reason = ?", reason)
+	}
+	if ref := c.Query("reference_id"); ref != "" {
+		q = q.Where("reference_id = ?", ref)
+	}
+	for param, cond := range map[string]string{"from": "created_at >= ?", "to": "created_at < ?"} {
+		raw := c.Query(param)
+		if raw == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Translate(c, "error.invalid_request")})
+			return
+		}
+		q = q.Where(cond, t)
+	}
+
+	limit := defaultMovementsLimit
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Translate(c, "error.invalid_request")})
+			return
+		}
+		if n < maxMovementsLimit {
+			limit = n
+		} else {
+			limit = maxMovementsLimit
+		}
+	}
+	if raw := c.Query("before_id"); raw != "" {
+		beforeID, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Translate(c, "error.invalid_request")})
+			return
+		}
+		q = q.Where("id < ?", beforeID)
+	}
+
+	var movements []model.InventoryMovement
+	if err := q.Order("id DESC").Limit(limit).Find(&movements).Error; err != nil {
+		log.DefaultLogger().Errorf("ListInventoryMovements DB error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.Translate(c, "error.list_movements_failed")})
+		return
+	}
+
+	resp := gin.H{"movements": movements}
+	if len(movements) == limit {
+		resp["next_before_id"] = movements[len(movements)-1].ID
+	}
+	c.JSON(http.StatusOK, resp)
+}
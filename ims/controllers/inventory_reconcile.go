@@ -0,0 +1,356 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"ims/model"
+	pr "ims/postgres"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omniful/go_commons/i18n"
+	"github.com/omniful/go_commons/log"
+	"gorm.io/gorm"
+)
+
+// Line statuses in the reconciliation report
+const (
+	reconcileMatch    = "match"
+	reconcileVariance = "variance"
+	reconcileApplied  = "applied"
+	reconcileFailed   = "failed"
+)
+
+// ReconcileLine compares the counted and recorded quantity of one hub/SKU
+type ReconcileLine struct {
+	HubCode         string `json:"hub_code"`
+	SKUCode         string `json:"sku_code"`
+	SystemQuantity  int64  `json:"system_quantity"`
+	CountedQuantity int64  `json:"counted_quantity"`
+	Variance        int64  `json:"variance"` // counted minus system
+	Status          string `json:"status"`
+	Error           string `json:"error,omitempty"`
+}
+
+// ReconcileSummary totals a reconciliation report
+type ReconcileSummary struct {
+	Lines        int   `json:"lines"`
+	Matched      int   `json:"matched"`
+	WithVariance int   `json:"with_variance"`
+	Failed       int   `json:"failed"`
+	NetVariance  int64 `json:"net_variance"`
+}
+
+// ReconcileResponse is the report of POST /inventory/reconcile
+type ReconcileResponse struct {
+	TenantID    string           `json:"tenant_id"`
+	SellerID    string           `json:"seller_id"`
+	Applied     bool             `json:"applied"`
+	ReferenceID string           `json:"reference_id,omitempty"`
+	Summary     ReconcileSummary `json:"summary"`
+	Lines       []ReconcileLine  `json:"lines"`
+	// stock recorded at a counted hub for SKUs the count did not include
+	Uncounted []SnapshotItem `json:"uncounted"`
+}
+
+// countParseError is a physical count CSV line that could not be read
+type countParseError struct {
+	line int
+	msg  string
+}
+
+func (e *countParseError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.line, e.msg)
+}
+
+var errReconcileFailed = errors.New("reconciliation lines failed")
+
+// ReconcileInventory handles POST /inventory/reconcile?tenant_id=&seller_id=&hub_code=&apply=&reference_id=.
+// The body is a physical count CSV, uploaded as "file" or sent as text/csv,
+// with sku_code and quantity columns and a hub_code column unless hub_code is
+// given in the query. Counts of the same hub/SKU are added up. Each line of
+// the report compares the count with the recorded quantity.
+//
+// With apply=true every variance is written as an adjustment movement under
+// reference_id, all in one transaction: if any line fails nothing is applied
+// and the answer is 422.
+func ReconcileInventory(c *gin.Context) {
+	tenantID := c.Query("tenant_id")
+	sellerID := c.Query("seller_id")
+	apply := c.Query("apply") == "true"
+	if tenantID == "" || sellerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Translate(c, "error.invalid_request")})
+		return
+	}
+
+	lines, err := readPhysicalCount(c, c.Query("hub_code"))
+	var parseErr *countParseError
+	switch {
+	case errors.As(err, &parseErr):
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Translate(c, "error.invalid_csv"), "line": parseErr.line, "detail": parseErr.msg})
+		return
+	case err != nil:
+		log.DefaultLogger().Warnf("ReconcileInventory bad body: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Translate(c, "error.invalid_request")})
+		return
+	}
+	if maxRows := bulkMaxRows(c); len(lines) == 0 || len(lines) > maxRows {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Translate(c, "error.bulk_row_count"), "max_rows": maxRows})
+		return
+	}
+
+	resp := ReconcileResponse{TenantID: tenantID, SellerID: sellerID, Lines: lines}
+	if apply {
+		resp.ReferenceID = c.Query("reference_id")
+		if resp.ReferenceID == "" {
+			resp.ReferenceID = "recount:" + time.Now().UTC().Format(time.RFC3339)
+		}
+	}
+
+	ctx := c.Request.Context()
+	db := pr.DB.GetMasterDB(ctx)
+	if resp.Uncounted, err = compareCount(db, tenantID, sellerID, resp.Lines); err != nil {
+		log.DefaultLogger().Errorf("ReconcileInventory DB error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.Translate(c, "error.reconcile_inventory_failed")})
+		return
+	}
+
+	status := http.StatusOK
+	if apply {
+		var events []pendingEvent
+		err = db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			events, err = applyCount(tx, tenantID, sellerID, resp.ReferenceID, resp.Lines)
+			return err
+		})
+		switch {
+		case errors.Is(err, errReconcileFailed):
+			// rolled back: nothing was applied after all
+			for i := range resp.Lines {
+				if resp.Lines[i].Status == reconcileApplied {
+					resp.Lines[i].Status = reconcileVariance
+				}
+			}
+			status = http.StatusUnprocessableEntity
+		case err != nil:
+			log.DefaultLogger().Errorf("ReconcileInventory apply DB error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.Translate(c, "error.reconcile_inventory_failed")})
+			return
+		default:
+			resp.Applied = true
+			for _, e := range events {
+				inventoryChanged(ctx, tenantID, sellerID, e.hub, e.sku, e.delta, e.reason)
+				checkStockLevel(ctx, tenantID, sellerID, e.hub, e.sku, e.delta)
+			}
+		}
+	}
+
+	resp.Summary.Lines = len(resp.Lines)
+	for _, l := range resp.Lines {
+		switch l.Status {
+		case reconcileMatch:
+			resp.Summary.Matched++
+		case reconcileFailed:
+			resp.Summary.Failed++
+		default:
+			resp.Summary.WithVariance++
+			resp.Summary.NetVariance += l.Variance
+		}
+	}
+	c.JSON(status, resp)
+}
+
+// readPhysicalCount parses the count CSV of the request. hubCode is used for
+// every line when the CSV has no hub_code column.
+func readPhysicalCount(c *gin.Context, hubCode string) ([]ReconcileLine, error) {
+	body := io.Reader(c.Request.Body)
+	if strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+		fh, err := c.FormFile("file")
+		if err != nil {
+			return nil, err
+		}
+		f, err := fh.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		body = f
+	}
+
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, &countParseError{line: 1, msg: "missing header"}
+	}
+	idx := make(map[string]int, len(header))
+	for i, col := range header {
+		idx[strings.TrimSpace(col)] = i
+	}
+	required := []string{"sku_code", "quantity"}
+	if hubCode == "" {
+		required = append(required, "hub_code")
+	}
+	for _, col := range required {
+		if _, ok := idx[col]; !ok {
+			return nil, &countParseError{line: 1, msg: "missing column " + col}
+		}
+	}
+
+	var lines []ReconcileLine
+	byKey := make(map[invKey]int)
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return lines, nil
+		}
+		if err != nil {
+			return nil, &countParseError{line: line, msg: err.Error()}
+		}
+
+		get := func(col string) string {
+			if i, ok := idx[col]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		key := invKey{hub: get("hub_code"), sku: get("sku_code")}
+		if key.hub == "" {
+			key.hub = hubCode
+		}
+		if key.hub == "" || key.sku == "" {
+			return nil, &countParseError{line: line, msg: "hub_code and sku_code are required"}
+		}
+		qty, err := strconv.ParseInt(get("quantity"), 10, 64)
+		if err != nil || qty < 0 {
+			return nil, &countParseError{line: line, msg: fmt.Sprintf("quantity %q is not a count", get("quantity"))}
+		}
+
+		// a SKU counted in several places of a hub is added up
+		if i, ok := byKey[key]; ok {
+			lines[i].CountedQuantity += qty
+			continue
+		}
+		byKey[key] = len(lines)
+		lines = append(lines, ReconcileLine{HubCode: key.hub, SKUCode: key.sku, CountedQuantity: qty})
+	}
+}
+
+// compareCount fills in the recorded quantity, variance and status of every
+// line and returns the stock at the counted hubs that the count left out
+func compareCount(db *gorm.DB, tenantID, sellerID string, lines []ReconcileLine) ([]SnapshotItem, error) {
+	var hubCodes, skuCodes []string
+	for _, l := range lines {
+		hubCodes = append(hubCodes, l.HubCode)
+		skuCodes = append(skuCodes, l.SKUCode)
+	}
+	knownHubs, knownSKUs, err := knownCodes(db, tenantID, sellerID, hubCodes, skuCodes)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []model.Inventory
+	if err := db.Where("tenant_id = ? AND seller_id = ? AND hub_code IN ?", tenantID, sellerID, hubCodes).
+		Order("hub_code, sku_code").
+		Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	system := make(map[invKey]model.Inventory, len(rows))
+	for _, r := range rows {
+		system[invKey{r.HubCode, r.SKUCode}] = r
+	}
+
+	counted := make(map[invKey]bool, len(lines))
+	for i := range lines {
+		l := &lines[i]
+		key := invKey{l.HubCode, l.SKUCode}
+		counted[key] = true
+
+		switch {
+		case !knownHubs[l.HubCode]:
+			l.Status, l.Error = reconcileFailed, fmt.Sprintf("hub %s does not exist", l.HubCode)
+			continue
+		case !knownSKUs[l.SKUCode]:
+			l.Status, l.Error = reconcileFailed, fmt.Sprintf("SKU %s does not exist", l.SKUCode)
+			continue
+		}
+		l.SystemQuantity = system[key].Quantity
+		l.Variance = l.CountedQuantity - l.SystemQuantity
+		l.Status = reconcileMatch
+		if l.Variance != 0 {
+			l.Status = reconcileVariance
+		}
+	}
+
+	uncounted := []SnapshotItem{}
+	for _, r := range rows {
+		if !counted[invKey{r.HubCode, r.SKUCode}] && r.Quantity != 0 {
+			uncounted = append(uncounted, SnapshotItem{HubCode: r.HubCode, SKUCode: r.SKUCode, Quantity: r.Quantity})
+		}
+	}
+	return uncounted, nil
+}
+
+// applyCount sets every line with a variance to its counted quantity inside
+// tx. It returns errReconcileFailed, so the transaction rolls back, when any
+// line failed or cannot be applied. The variance is taken again from the
+// quantity each row held when it was written.
+func applyCount(tx *gorm.DB, tenantID, sellerID, referenceID string, lines []ReconcileLine) ([]pendingEvent, error) {
+	for _, l := range lines {
+		if l.Status == reconcileFailed {
+			return nil, errReconcileFailed
+		}
+	}
+
+	var events []pendingEvent
+	failed := false
+	now := time.Now().UTC()
+	for i := range lines {
+		l := &lines[i]
+		if l.Status == reconcileMatch {
+			continue
+		}
+
+		row, err := upsertInventory(tx, UpsertInventoryRequest{
+			TenantID:    tenantID,
+			SellerID:    sellerID,
+			HubCode:     l.HubCode,
+			SKUCode:     l.SKUCode,
+			Quantity:    l.CountedQuantity,
+			Mode:        UpsertModeSet,
+			Reason:      model.MovementAdjustment,
+			ReferenceID: referenceID,
+		}, now)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			l.Status, l.Error = reconcileFailed, "counted quantity is below reserved stock"
+			failed = true
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		l.SystemQuantity = row.OldQuantity
+		l.Variance = row.Quantity - row.OldQuantity
+		l.Status = reconcileApplied
+		if l.Variance != 0 {
+			reason := model.InventoryReasonUpdated
+			if row.Inserted {
+				reason = model.InventoryReasonCreated
+			}
+			events = append(events, pendingEvent{hub: l.HubCode, sku: l.SKUCode, delta: l.Variance, reason: reason})
+		}
+	}
+	if failed {
+		return nil, errReconcileFailed
+	}
+	return events, nil
+}
@@ -0,0 +1,312 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"ims/model"
+	pr "ims/postgres"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgconn"
+	"github.com/omniful/go_commons/i18n"
+	"github.com/omniful/go_commons/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// errInsufficientInventory aborts a reservation transaction when any line is short
+	errInsufficientInventory = errors.New("insufficient inventory")
+	// errInventoryMissing aborts a release when a line has no inventory row
+	errInventoryMissing = errors.New("inventory not found")
+	// errAlreadyReleased aborts a release whose reference was released before
+	errAlreadyReleased = errors.New("reference already released")
+	// errInventoryReserved refuses to delete or move a row reservations hold stock of
+	errInventoryReserved = errors.New("inventory has reserved stock")
+)
+
+// isUniqueViolation reports whether err is Postgres refusing a duplicate key
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+// InventoryLine is one hub/SKU quantity in a multi-line request
+type InventoryLine struct {
+	HubCode  string `json:"hub_code" binding:"required"`
+	SKUCode  string `json:"sku_code" binding:"required"`
+	Quantity int64  `json:"quantity" binding:"required,gt=0"`
+}
+
+// ReservedLine reports the stock left after a line was decremented
+type ReservedLine struct {
+	HubCode   string `json:"hub_code"`
+	SKUCode   string `json:"sku_code"`
+	Quantity  int64  `json:"quantity"`
+	Remaining int64  `json:"remaining"`
+}
+
+// ShortLine reports a line that could not be fulfilled
+type ShortLine struct {
+	HubCode   string `json:"hub_code"`
+	SKUCode   string `json:"sku_code"`
+	Requested int64  `json:"requested"`
+	Available int64  `json:"available"`
+}
+
+// ReserveInventoryRequest is the body of POST /inventory/reserve
+type ReserveInventoryRequest struct {
+	TenantID    string          `json:"tenant_id" binding:"required"`
+	SellerID    string          `json:"seller_id" binding:"required"`
+	ReferenceID string          `json:"reference_id"` // e.g. the OMS order id, used for logging and the ledger
+	Lines       []InventoryLine `json:"lines" binding:"required,min=1,dive"`
+}
+
+// ReserveInventory handles POST /inventory/reserve.
+// All lines are decremented in one transaction or none are.
+func ReserveInventory(c *gin.Context) {
+	var req ReserveInventoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Translate(c, "error.invalid_request")})
+		return
+	}
+
+	var reserved []ReservedLine
+	var short []ShortLine
+
+	db := pr.DB.GetMasterDB(c.Request.Context())
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		reserved, short, err = decrementLines(tx, req.TenantID, req.SellerID, model.MovementOrderConsume, req.ReferenceID, req.Lines)
+		return err
+	})
+
+	if errors.Is(err, errInsufficientInventory) {
+		log.Infof("Reservation rejected: ref=%s short=%+v", req.ReferenceID, short)
+		c.JSON(http.StatusConflict, gin.H{
+			"error":       i18n.Translate(c, "error.insufficient_inventory"),
+			"short_lines": short,
+		})
+		return
+	}
+	if err != nil {
+		log.DefaultLogger().Errorf("ReserveInventory DB error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.Translate(c, "error.reserve_inventory_failed")})
+		return
+	}
+
+	for _, l := range reserved {
+		inventoryChanged(c.Request.Context(), req.TenantID, req.SellerID, l.HubCode, l.SKUCode, -l.Quantity, model.InventoryReasonConsumed)
+		checkStockLevel(c.Request.Context(), req.TenantID, req.SellerID, l.HubCode, l.SKUCode, -l.Quantity)
+	}
+
+	log.Infof("Inventory reserved: ref=%s lines=%d", req.ReferenceID, len(reserved))
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Inventory reserved",
+		"lines":   reserved,
+	})
+}
+
+// ReleaseInventory handles POST /inventory/release, the inverse of
+// /inventory/consume and /inventory/reserve: every line's quantity is added
+// back to on-hand stock in one transaction. A reference is released at most
+// once; releasing it again answers 409 and changes nothing.
+func ReleaseInventory(c *gin.Context) {
+	var req ReserveInventoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.ReferenceID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Translate(c, "error.invalid_request")})
+		return
+	}
+
+	lines := mergeLines(req.Lines)
+	var released []ReservedLine
+	var missing InventoryLine
+
+	db := pr.DB.GetMasterDB(c.Request.Context())
+	err := db.Transaction(func(tx *gorm.DB) error {
+		claim := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.InventoryRelease{
+			TenantID:    req.TenantID,
+			SellerID:    req.SellerID,
+			ReferenceID: req.ReferenceID,
+		})
+		if claim.Error != nil {
+			return claim.Error
+		}
+		if claim.RowsAffected == 0 {
+			return errAlreadyReleased
+		}
+
+		var err error
+		released, missing, err = incrementLines(tx, req.TenantID, req.SellerID, model.MovementCancelRelease, req.ReferenceID, lines, false)
+		return err
+	})
+	switch {
+	case errors.Is(err, errAlreadyReleased):
+		log.Infof("Release rejected: ref=%s was released before", req.ReferenceID)
+		c.JSON(http.StatusConflict, gin.H{"error": i18n.Translate(c, "error.inventory_already_released")})
+		return
+	case errors.Is(err, errInventoryMissing):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":    i18n.Translate(c, "error.inventory_not_found"),
+			"hub_code": missing.HubCode,
+			"sku_code": missing.SKUCode,
+		})
+		return
+	case err != nil:
+		log.DefaultLogger().Errorf("ReleaseInventory DB error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.Translate(c, "error.release_inventory_failed")})
+		return
+	}
+
+	for _, l := range released {
+		inventoryChanged(c.Request.Context(), req.TenantID, req.SellerID, l.HubCode, l.SKUCode, l.Quantity, model.InventoryReasonCancelRelease)
+	}
+
+	log.Infof("Inventory released: ref=%s lines=%d", req.ReferenceID, len(released))
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Inventory released",
+		"lines":   released,
+	})
+}
+
+// incrementLines adds each line to on-hand stock, locking rows in line order,
+// and records a movement with reason for referenceID. A row that does not
+// exist is created with the line's quantity when create is set; otherwise
+// the line is returned with errInventoryMissing.
+func incrementLines(tx *gorm.DB, tenantID, sellerID, reason, referenceID string, lines []InventoryLine, create bool) ([]ReservedLine, InventoryLine, error) {
+	now := time.Now().UTC()
+	released := make([]ReservedLine, 0, len(lines))
+
+	for _, l := range lines {
+		var row model.Inventory
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("tenant_id = ? AND seller_id = ? AND hub_code = ? AND sku_code = ?", tenantID, sellerID, l.HubCode, l.SKUCode).
+			Order("id").
+			First(&row).Error
+
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound) && !create:
+			return nil, l, errInventoryMissing
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			row = model.Inventory{
+				TenantID:  tenantID,
+				SellerID:  sellerID,
+				HubCode:   l.HubCode,
+				SKUCode:   l.SKUCode,
+				Quantity:  l.Quantity,
+				UpdatedAt: now,
+			}
+			if err := tx.Create(&row).Error; err != nil {
+				return nil, l, err
+			}
+		case err != nil:
+			return nil, l, err
+		default:
+			row.Quantity += l.Quantity
+			if err := tx.Model(&model.Inventory{}).
+				Where("id = ?", row.ID).
+				Updates(map[string]interface{}{
+					"quantity":   row.Quantity,
+					"updated_at": now,
+				}).Error; err != nil {
+				return nil, l, err
+			}
+		}
+		if err := recordMovement(tx, row, l.Quantity, reason, referenceID); err != nil {
+			return nil, l, err
+		}
+
+		released = append(released, ReservedLine{HubCode: l.HubCode, SKUCode: l.SKUCode, Quantity: l.Quantity, Remaining: row.Quantity - row.Reserved})
+	}
+	return released, InventoryLine{}, nil
+}
+
+// mergeLines sums duplicate hub/SKU lines and sorts them so that every
+// transaction locks inventory rows in the same order.
+func mergeLines(lines []InventoryLine) []InventoryLine {
+	byKey := make(map[[2]string]int)
+	var merged []InventoryLine
+	for _, l := range lines {
+		k := [2]string{l.HubCode, l.SKUCode}
+		if i, ok := byKey[k]; ok {
+			merged[i].Quantity += l.Quantity
+			continue
+		}
+		byKey[k] = len(merged)
+		merged = append(merged, l)
+	}
+
+	sort.Slice(merged, func(i, j int) bool {
+		if merged[i].HubCode != merged[j].HubCode {
+			return merged[i].HubCode < merged[j].HubCode
+		}
+		return merged[i].SKUCode < merged[j].SKUCode
+	})
+	return merged
+}
+
+// lockLines locks the inventory row of every line, in the order given, and
+// reports each line whose available stock is below the requested quantity.
+// A missing row counts as zero stock.
+func lockLines(tx *gorm.DB, tenantID, sellerID string, lines []InventoryLine) ([]model.Inventory, []ShortLine, error) {
+	rows := make([]model.Inventory, len(lines))
+	var short []ShortLine
+
+	for i, l := range lines {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("tenant_id = ? AND seller_id = ? AND hub_code = ? AND sku_code = ?", tenantID, sellerID, l.HubCode, l.SKUCode).
+			Order("id").
+			First(&rows[i]).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			short = append(short, ShortLine{HubCode: l.HubCode, SKUCode: l.SKUCode, Requested: l.Quantity})
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if rows[i].Available < l.Quantity {
+			short = append(short, ShortLine{HubCode: l.HubCode, SKUCode: l.SKUCode, Requested: l.Quantity, Available: rows[i].Available})
+		}
+	}
+	return rows, short, nil
+}
+
+// decrementLines locks and decrements every line inside tx, recording a
+// movement with reason for referenceID. When any line is short it returns
+// errInsufficientInventory along with all short lines, and the caller's
+// transaction rolls back.
+func decrementLines(tx *gorm.DB, tenantID, sellerID, reason, referenceID string, lines []InventoryLine) ([]ReservedLine, []ShortLine, error) {
+	lines = mergeLines(lines)
+
+	rows, short, err := lockLines(tx, tenantID, sellerID, lines)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(short) > 0 {
+		return nil, short, errInsufficientInventory
+	}
+
+	now := time.Now().UTC()
+	reserved := make([]ReservedLine, 0, len(lines))
+	for i, l := range lines {
+		newQty := rows[i].Quantity - l.Quantity
+		if err := tx.Model(&model.Inventory{}).
+			Where("id = ?", rows[i].ID).
+			Updates(map[string]interface{}{
+				"quantity":   newQty,
+				"updated_at": now,
+			}).Error; err != nil {
+			return nil, nil, err
+		}
+		rows[i].Quantity = newQty
+		if err := recordMovement(tx, rows[i], -l.Quantity, reason, referenceID); err != nil {
+			return nil, nil, err
+		}
+		reserved = append(reserved, ReservedLine{HubCode: l.HubCode, SKUCode: l.SKUCode, Quantity: l.Quantity, Remaining: newQty})
+	}
+
+	return reserved, nil, nil
+}
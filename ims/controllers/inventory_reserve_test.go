@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeLines(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []InventoryLine
+		want  []InventoryLine
+	}{
+		{
+			name:  "single line",
+			lines: []InventoryLine{{HubCode: "H1", SKUCode: "A", Quantity: 1}},
+			want:  []InventoryLine{{HubCode: "H1", SKUCode: "A", Quantity: 1}},
+		},
+		{
+			name: "duplicates are summed",
+			lines: []InventoryLine{
+				{HubCode: "H1", SKUCode: "A", Quantity: 1},
+				{HubCode: "H1", SKUCode: "B", Quantity: 2},
+				{HubCode: "H1", SKUCode: "A", Quantity: 3},
+			},
+			want: []InventoryLine{
+				{HubCode: "H1", SKUCode: "A", Quantity: 4},
+				{HubCode: "H1", SKUCode: "B", Quantity: 2},
+			},
+		},
+		{
+			name: "sorted by hub then SKU",
+			lines: []InventoryLine{
+				{HubCode: "H2", SKUCode: "A", Quantity: 1},
+				{HubCode: "H1", SKUCode: "B", Quantity: 1},
+				{HubCode: "H1", SKUCode: "A", Quantity: 1},
+			},
+			want: []InventoryLine{
+				{HubCode: "H1", SKUCode: "A", Quantity: 1},
+				{HubCode: "H1", SKUCode: "B", Quantity: 1},
+				{HubCode: "H2", SKUCode: "A", Quantity: 1},
+			},
+		},
+		{
+			name: "same SKU at two hubs stays apart",
+			lines: []InventoryLine{
+				{HubCode: "H2", SKUCode: "A", Quantity: 5},
+				{HubCode: "H1", SKUCode: "A", Quantity: 2},
+			},
+			want: []InventoryLine{
+				{HubCode: "H1", SKUCode: "A", Quantity: 2},
+				{HubCode: "H2", SKUCode: "A", Quantity: 5},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mergeLines(tt.lines); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"ims/model"
+	pr "ims/postgres"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omniful/go_commons/i18n"
+	"github.com/omniful/go_commons/log"
+	"gorm.io/gorm"
+)
+
+// SnapshotItem is the on-hand quantity of a hub/SKU at a point in time
+type SnapshotItem struct {
+	HubCode        string    `json:"hub_code"`
+	SKUCode        string    `json:"sku_code"`
+	Quantity       int64     `json:"quantity"`
+	LastMovementID int64     `json:"last_movement_id"`
+	LastMovementAt time.Time `json:"last_movement_at"`
+}
+
+// GetInventorySnapshot handles GET /inventory/snapshot?tenant_id=&seller_id=&as_of=.
+// The quantity of each hub/SKU is the balance of its last movement at or
+// before as_of (RFC 3339, default now). hub_code and sku_code narrow the
+// result; hub/SKUs without history by then are left out.
+func GetInventorySnapshot(c *gin.Context) {
+	tenantID := c.Query("tenant_id")
+	sellerID := c.Query("seller_id")
+	if tenantID == "" || sellerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Translate(c, "error.invalid_request")})
+		return
+	}
+
+	asOf := time.Now().UTC()
+	if raw := c.Query("as_of"); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Translate(c, "error.invalid_request")})
+			return
+		}
+		asOf = t.UTC()
+	}
+
+	db := pr.DB.GetSlaveDB(c.Request.Context())
+	items, err := inventorySnapshot(db, tenantID, sellerID, c.Query("hub_code"), c.Query("sku_code"), asOf)
+	if err != nil {
+		log.DefaultLogger().Errorf("GetInventorySnapshot DB error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.Translate(c, "error.inventory_snapshot_failed")})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tenant_id": tenantID,
+		"seller_id": sellerID,
+		"as_of":     asOf,
+		"items":     items,
+	})
+}
+
+// inventorySnapshot replays the ledger up to asOf. hubCode and skuCode are
+// optional filters.
+func inventorySnapshot(db *gorm.DB, tenantID, sellerID, hubCode, skuCode string, asOf time.Time) ([]SnapshotItem, error) {
+	q := db.Model(&model.InventoryMovement{}).
+		Select("DISTINCT ON (hub_code, sku_code) hub_code, sku_code, balance AS quantity, id AS last_movement_id, created_at AS last_movement_at").
+		Where("tenant_id = ? AND seller_id = ? AND created_at <= ?", tenantID, sellerID, asOf)
+	if hubCode != "" {
+		q = q.Where("hub_code = ?", hubCode)
+	}
+	if skuCode != "" {
+		q = q.Where("sku_code = ?", skuCode)
+	}
+
+	items := []SnapshotItem{}
+	if err := q.Order("hub_code, sku_code, id DESC").Scan(&items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,178 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"ims/model"
+	pr "ims/postgres"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omniful/go_commons/i18n"
+	"github.com/omniful/go_commons/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Upsert modes
+const (
+	UpsertModeSet   = "set"   // quantity becomes the given value
+	UpsertModeDelta = "delta" // the given value is added to quantity
+)
+
+// UpsertInventoryRequest is the body of PUT /inventory/upsert
+type UpsertInventoryRequest struct {
+	TenantID string `json:"tenant_id" binding:"required"`
+	SellerID string `json:"seller_id" binding:"required"`
+	HubCode  string `json:"hub_code" binding:"required"`
+	SKUCode  string `json:"sku_code" binding:"required"`
+	Quantity int64  `json:"quantity"`
+	Mode     string `json:"mode"` // set (default) or delta
+	// ledger reason; by default restock when stock is added, adjustment otherwise
+	Reason      string `json:"reason"`
+	ReferenceID string `json:"reference_id"`
+}
+
+// upsertedInventory is a row as an upsert left it, with the quantity it had
+// before; OldQuantity is 0 when the upsert created the row
+type upsertedInventory struct {
+	model.Inventory
+	Inserted    bool
+	OldQuantity int64
+}
+
+var errUpsertMissing = errors.New("inventory not found")
+
+// UpsertInventory handles PUT /inventory/upsert. In set mode quantity becomes
+// the given value; in delta mode the value is added to it. Either way the row
+// is created if it does not exist. On-hand stock never drops below what
+// reservations hold.
+func UpsertInventory(c *gin.Context) {
+	var req UpsertInventoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Translate(c, "error.invalid_request")})
+		return
+	}
+	if req.Mode == "" {
+		req.Mode = UpsertModeSet
+	}
+	if (req.Mode != UpsertModeSet && req.Mode != UpsertModeDelta) || (req.Mode == UpsertModeSet && req.Quantity < 0) ||
+		(req.Reason != "" && !model.ValidMovementReason(req.Reason)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Translate(c, "error.invalid_request")})
+		return
+	}
+
+	db := pr.DB.GetMasterDB(c.Request.Context())
+	inv := model.Inventory{TenantID: req.TenantID, SellerID: req.SellerID, HubCode: req.HubCode, SKUCode: req.SKUCode}
+	if !requireInventoryParents(c, db, inv) {
+		return
+	}
+
+	var row *upsertedInventory
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		row, err = upsertInventory(tx, req, time.Now().UTC())
+		return err
+	})
+	switch {
+	case errors.Is(err, errUpsertMissing):
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.Translate(c, "error.inventory_not_found")})
+		return
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusConflict, gin.H{"error": i18n.Translate(c, "error.quantity_below_reserved")})
+		return
+	case err != nil:
+		log.DefaultLogger().Errorf("UpsertInventory DB error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.Translate(c, "error.upsert_inventory_failed")})
+		return
+	}
+
+	inventory := row.Inventory
+	inventory.Available = inventory.Quantity - inventory.Reserved
+
+	reason, status := model.InventoryReasonUpdated, http.StatusOK
+	if row.Inserted {
+		reason, status = model.InventoryReasonCreated, http.StatusCreated
+	}
+	// reserved is unchanged, so available moves with quantity
+	inventoryChanged(c.Request.Context(), inventory.TenantID, inventory.SellerID, inventory.HubCode, inventory.SKUCode, inventory.Quantity-row.OldQuantity, reason)
+	before := inventory
+	before.Quantity = row.OldQuantity
+	before.Available = before.Quantity - before.Reserved
+	alertStockLevel(c.Request.Context(), before, inventory)
+
+	c.JSON(status, inventory)
+}
+
+// upsertInventory runs the upsert for req and records it in the ledger, so db
+// should be a transaction. The row is locked before it is read, so the
+// quantity it had, and the ledger delta, are those the change was applied
+// to. It returns gorm.ErrRecordNotFound when the change would leave less on
+// hand than is reserved, and errUpsertMissing when a negative delta targets
+// a row that does not exist.
+func upsertInventory(db *gorm.DB, req UpsertInventoryRequest, now time.Time) (*upsertedInventory, error) {
+	var row *upsertedInventory
+	for row == nil {
+		var current model.Inventory
+		err := db.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("tenant_id = ? AND seller_id = ? AND hub_code = ? AND sku_code = ?", req.TenantID, req.SellerID, req.HubCode, req.SKUCode).
+			First(&current).Error
+		switch {
+		case err == nil:
+			quantity := req.Quantity
+			if req.Mode == UpsertModeDelta {
+				quantity += current.Quantity
+			}
+			if quantity < current.Reserved {
+				return nil, gorm.ErrRecordNotFound
+			}
+			if err := db.Model(&model.Inventory{}).
+				Where("id = ?", current.ID).
+				Updates(map[string]interface{}{
+					"quantity":   quantity,
+					"updated_at": now,
+				}).Error; err != nil {
+				return nil, err
+			}
+			row = &upsertedInventory{Inventory: current, OldQuantity: current.Quantity}
+			row.Quantity, row.UpdatedAt = quantity, now
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return nil, err
+		case req.Mode == UpsertModeDelta && req.Quantity < 0:
+			// a negative delta cannot create a row
+			return nil, errUpsertMissing
+		default:
+			created := model.Inventory{
+				TenantID:  req.TenantID,
+				SellerID:  req.SellerID,
+				HubCode:   req.HubCode,
+				SKUCode:   req.SKUCode,
+				Quantity:  req.Quantity,
+				UpdatedAt: now,
+			}
+			result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&created)
+			if result.Error != nil {
+				return nil, result.Error
+			}
+			// nothing created means a concurrent upsert created the row
+			// first; the next round locks and changes it
+			if result.RowsAffected == 1 {
+				row = &upsertedInventory{Inventory: created, Inserted: true}
+			}
+		}
+	}
+
+	delta := row.Quantity - row.OldQuantity
+	reason := req.Reason
+	if reason == "" {
+		reason = model.MovementAdjustment
+		if delta > 0 {
+			reason = model.MovementRestock
+		}
+	}
+	if err := recordMovement(db, row.Inventory, delta, reason, req.ReferenceID); err != nil {
+		return nil, err
+	}
+	return row, nil
+}
@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"net/http"
+
+	"ims/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omniful/go_commons/i18n"
+	"github.com/omniful/go_commons/log"
+	"gorm.io/gorm"
+)
+
+// Tenants own sellers, sellers own hubs and SKUs, and inventory belongs to a
+// hub and a SKU of the same seller. The migrations enforce this with foreign
+// keys; the helpers below check it first so callers get a readable error.
+
+// parentExists reports whether a row of model matching query exists
+func parentExists(db *gorm.DB, model interface{}, query string, args ...interface{}) (bool, error) {
+	var n int64
+	if err := db.Model(model).Where(query, args...).Limit(1).Count(&n).Error; err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// requireParent answers 422 with notFoundKey when the parent row is missing,
+// or 500 when it could not be looked up. It returns false if it answered.
+func requireParent(c *gin.Context, db *gorm.DB, notFoundKey string, model interface{}, query string, args ...interface{}) bool {
+	ok, err := parentExists(db, model, query, args...)
+	if err != nil {
+		log.DefaultLogger().Errorf("Parent lookup for %s DB error: %v", notFoundKey, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.Translate(c, "error.validate_relations_failed")})
+		return false
+	}
+	if !ok {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": i18n.Translate(c, notFoundKey)})
+		return false
+	}
+	return true
+}
+
+// dependent is a kind of row that still references a record being deleted
+type dependent struct {
+	name  string
+	model interface{}
+	query string
+	args  []interface{}
+}
+
+// requireNoDependents answers 409 with the number of rows of each dependent
+// kind that still reference a record, or 500 when they could not be counted.
+// Deleting a record, or changing the key its dependents reference, is
+// restricted rather than cascaded: the dependents have to go first. It
+// returns false if it answered.
+func requireNoDependents(c *gin.Context, db *gorm.DB, deps ...dependent) bool {
+	counts := gin.H{}
+	for _, d := range deps {
+		var n int64
+		if err := db.Model(d.model).Where(d.query, d.args...).Count(&n).Error; err != nil {
+			log.DefaultLogger().Errorf("Dependent count of %s DB error: %v", d.name, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.Translate(c, "error.validate_relations_failed")})
+			return false
+		}
+		if n > 0 {
+			counts[d.name] = n
+		}
+	}
+	if len(counts) > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": i18n.Translate(c, "error.has_dependents"), "dependents": counts})
+		return false
+	}
+	return true
+}
+
+// requireInventoryParents checks that the hub and the SKU of an inventory
+// row exist for its tenant and seller
+func requireInventoryParents(c *gin.Context, db *gorm.DB, inv model.Inventory) bool {
+	return requireParent(c, db, "error.hub_not_found", &model.Hub{}, "tenant_id = ? AND seller_id = ? AND hub_code = ?", inv.TenantID, inv.SellerID, inv.HubCode) &&
+		requireParent(c, db, "error.sku_not_found", &model.SKU{}, "tenant_id = ? AND seller_id = ? AND sku_code = ?", inv.TenantID, inv.SellerID, inv.SKUCode)
+}
@@ -0,0 +1,390 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"ims/model"
+	pr "ims/postgres"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omniful/go_commons/config"
+	"github.com/omniful/go_commons/i18n"
+	"github.com/omniful/go_commons/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errReservationNotFound = errors.New("reservation not found")
+	errReservationClosed   = errors.New("reservation is no longer held")
+	errReservationExpired  = errors.New("reservation expired")
+)
+
+const defaultReservationTTL = 15 * time.Minute
+
+// CreateReservationRequest is the body of POST /reservations
+type CreateReservationRequest struct {
+	TenantID    string          `json:"tenant_id" binding:"required"`
+	SellerID    string          `json:"seller_id" binding:"required"`
+	ReferenceID string          `json:"reference_id"`
+	TTLSeconds  int64           `json:"ttl_seconds"` // falls back to reservations.default_ttl
+	Lines       []InventoryLine `json:"lines" binding:"required,min=1,dive"`
+}
+
+// CreateReservation handles POST /reservations.
+// Holds stock for every line or, if any line is short, holds nothing. A
+// reference has at most one open hold: asking again for the same lines
+// answers 200 with the hold it already has, asking for other lines 409.
+func CreateReservation(c *gin.Context) {
+	var req CreateReservationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Translate(c, "error.invalid_request")})
+		return
+	}
+
+	ttl := time.Duration(req.TTLSeconds) * time.Second
+	if ttl <= 0 {
+		ttl = config.GetDuration(c, "reservations.default_ttl")
+	}
+	if ttl <= 0 {
+		ttl = defaultReservationTTL
+	}
+
+	lines := mergeLines(req.Lines)
+	now := time.Now().UTC()
+	ctx := c.Request.Context()
+
+	if req.ReferenceID != "" {
+		existing, err := heldReservation(ctx, req.TenantID, req.SellerID, req.ReferenceID, now)
+		if err != nil {
+			log.DefaultLogger().Errorf("CreateReservation DB error: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.Translate(c, "error.create_reservation_failed")})
+			return
+		}
+		if existing != nil {
+			answerHeldReservation(c, existing, lines)
+			return
+		}
+	}
+
+	reservation := model.Reservation{
+		TenantID:    req.TenantID,
+		SellerID:    req.SellerID,
+		ReferenceID: req.ReferenceID,
+		Status:      model.ReservationHeld,
+		ExpiresAt:   now.Add(ttl),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	for _, l := range lines {
+		reservation.Lines = append(reservation.Lines, model.ReservationLine{HubCode: l.HubCode, SKUCode: l.SKUCode, Quantity: l.Quantity})
+	}
+
+	var short []ShortLine
+	db := pr.DB.GetMasterDB(ctx)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if short, err = holdLines(tx, req.TenantID, req.SellerID, lines); err != nil {
+			return err
+		}
+		return tx.Create(&reservation).Error
+	})
+
+	if isUniqueViolation(err) {
+		// a concurrent request held stock for the reference first; ours rolled back
+		existing, findErr := heldReservation(ctx, req.TenantID, req.SellerID, req.ReferenceID, now)
+		if findErr == nil && existing != nil {
+			answerHeldReservation(c, existing, lines)
+			return
+		}
+	}
+	if errors.Is(err, errInsufficientInventory) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":       i18n.Translate(c, "error.insufficient_inventory"),
+			"short_lines": short,
+		})
+		return
+	}
+	if err != nil {
+		log.DefaultLogger().Errorf("CreateReservation DB error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.Translate(c, "error.create_reservation_failed")})
+		return
+	}
+
+	for _, l := range lines {
+		inventoryChanged(ctx, req.TenantID, req.SellerID, l.HubCode, l.SKUCode, -l.Quantity, model.InventoryReasonReserved)
+		checkStockLevel(ctx, req.TenantID, req.SellerID, l.HubCode, l.SKUCode, -l.Quantity)
+	}
+
+	log.Infof("Reservation held: id=%s ref=%s expires_at=%s", reservation.ID, reservation.ReferenceID, reservation.ExpiresAt)
+	c.JSON(http.StatusCreated, reservation)
+}
+
+// heldReservation returns the open hold of a reference with its lines, or nil
+// if it has none. A hold past its expiry is expired first rather than handed
+// out, since it can no longer be committed.
+func heldReservation(ctx context.Context, tenantID, sellerID, referenceID string, now time.Time) (*model.Reservation, error) {
+	var reservation model.Reservation
+	db := pr.DB.GetMasterDB(ctx)
+	err := db.Where("tenant_id = ? AND seller_id = ? AND reference_id = ? AND status = ?", tenantID, sellerID, referenceID, model.ReservationHeld).
+		First(&reservation).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !now.Before(reservation.ExpiresAt) {
+		_, err := closeReservation(ctx, reservation.ID, model.ReservationExpired, now)
+		if err != nil && !errors.Is(err, errReservationClosed) {
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	if err := db.Where("reservation_id = ?", reservation.ID).Order("hub_code, sku_code").Find(&reservation.Lines).Error; err != nil {
+		return nil, err
+	}
+	return &reservation, nil
+}
+
+// answerHeldReservation answers a request for a reference that already has
+// an open hold: 200 with the hold if it is for the same merged lines, 409
+// otherwise
+func answerHeldReservation(c *gin.Context, existing *model.Reservation, lines []InventoryLine) {
+	if !sameLines(existing.Lines, lines) {
+		log.Infof("Reservation rejected: ref=%s already holds %s", existing.ReferenceID, existing.ID)
+		c.JSON(http.StatusConflict, gin.H{"error": i18n.Translate(c, "error.reservation_reference_held"), "reservation": existing})
+		return
+	}
+
+	log.Infof("Reservation reused: id=%s ref=%s", existing.ID, existing.ReferenceID)
+	c.JSON(http.StatusOK, existing)
+}
+
+// sameLines reports whether a hold's lines are the merged lines, both sorted
+// by hub and SKU
+func sameLines(held []model.ReservationLine, lines []InventoryLine) bool {
+	if len(held) != len(lines) {
+		return false
+	}
+	for i, l := range held {
+		if l.HubCode != lines[i].HubCode || l.SKUCode != lines[i].SKUCode || l.Quantity != lines[i].Quantity {
+			return false
+		}
+	}
+	return true
+}
+
+// GetReservation handles GET /reservations/:id
+func GetReservation(c *gin.Context) {
+	id := c.Param("id")
+	var reservation model.Reservation
+
+	db := pr.DB.GetSlaveDB(c.Request.Context())
+	if err := db.Preload("Lines").First(&reservation, "id = ?", id).Error; err != nil {
+		log.DefaultLogger().Errorf("GetReservation DB error: %v", err)
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.Translate(c, "error.reservation_not_found")})
+		return
+	}
+
+	c.JSON(http.StatusOK, reservation)
+}
+
+// CommitReservation handles POST /reservations/:id/commit.
+// Held stock is removed from on-hand; committing twice is a no-op.
+func CommitReservation(c *gin.Context) {
+	closeReservationHandler(c, model.ReservationCommitted)
+}
+
+// ReleaseReservation handles POST /reservations/:id/release.
+// Held stock is returned to availability.
+func ReleaseReservation(c *gin.Context) {
+	closeReservationHandler(c, model.ReservationReleased)
+}
+
+// ExpireReservation handles POST /reservations/:id/expire
+func ExpireReservation(c *gin.Context) {
+	closeReservationHandler(c, model.ReservationExpired)
+}
+
+func closeReservationHandler(c *gin.Context, target string) {
+	id := c.Param("id")
+
+	reservation, err := closeReservation(c.Request.Context(), id, target, time.Now().UTC())
+
+	switch {
+	case errors.Is(err, errReservationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.Translate(c, "error.reservation_not_found")})
+		return
+	case errors.Is(err, errReservationExpired):
+		c.JSON(http.StatusConflict, gin.H{"error": i18n.Translate(c, "error.reservation_expired"), "reservation": reservation})
+		return
+	case errors.Is(err, errReservationClosed):
+		c.JSON(http.StatusConflict, gin.H{"error": i18n.Translate(c, "error.reservation_closed"), "reservation": reservation})
+		return
+	case err != nil:
+		log.DefaultLogger().Errorf("Reservation %s -> %s DB error: %v", id, target, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.Translate(c, "error.update_reservation_failed")})
+		return
+	}
+
+	log.Infof("Reservation %s is now %s", id, reservation.Status)
+	c.JSON(http.StatusOK, reservation)
+}
+
+// ExpireDueReservations returns every held reservation past its expiry to
+// stock, at most limit per call, leaving out the ids in skip. A reservation
+// that cannot be expired is logged and returned in failed rather than
+// holding up the ones behind it. It is driven by the reservation sweeper.
+func ExpireDueReservations(ctx context.Context, limit int, skip []string) (expired int, failed []string, err error) {
+	db := pr.DB.GetMasterDB(ctx)
+	now := time.Now().UTC()
+
+	query := db.Model(&model.Reservation{}).Where("status = ? AND expires_at <= ?", model.ReservationHeld, now)
+	if len(skip) > 0 {
+		query = query.Where("id NOT IN ?", skip)
+	}
+	var ids []string
+	if err := query.Order("expires_at").Limit(limit).Pluck("id", &ids).Error; err != nil {
+		return 0, nil, err
+	}
+
+	for _, id := range ids {
+		_, err := closeReservation(ctx, id, model.ReservationExpired, now)
+		if errors.Is(err, errReservationClosed) {
+			// committed or released concurrently
+			continue
+		}
+		if err != nil {
+			log.DefaultLogger().Errorf("Failed to expire reservation %s: %v", id, err)
+			failed = append(failed, id)
+			continue
+		}
+		expired++
+	}
+	return expired, failed, nil
+}
+
+// closeReservation moves a held reservation to target and settles its lines.
+// Committing a reservation that is past its expiry expires it instead.
+func closeReservation(ctx context.Context, id, target string, now time.Time) (*model.Reservation, error) {
+	var reservation model.Reservation
+	var result error
+	changed := false
+
+	db := pr.DB.GetMasterDB(ctx)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&reservation, "id = ?", id).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errReservationNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("reservation_id = ?", id).Order("hub_code, sku_code").Find(&reservation.Lines).Error; err != nil {
+			return err
+		}
+
+		if reservation.Status == target {
+			return nil
+		}
+		if reservation.Status != model.ReservationHeld {
+			result = errReservationClosed
+			return nil
+		}
+
+		if target == model.ReservationCommitted && !now.Before(reservation.ExpiresAt) {
+			target = model.ReservationExpired
+			result = errReservationExpired
+		}
+
+		if err := settleLines(tx, &reservation, target == model.ReservationCommitted); err != nil {
+			return err
+		}
+
+		reservation.Status = target
+		reservation.UpdatedAt = now
+		changed = true
+		return tx.Model(&model.Reservation{}).
+			Where("id = ?", id).
+			Updates(map[string]interface{}{
+				"status":     target,
+				"updated_at": now,
+			}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Released and expired holds put stock back on the shelf
+	if changed && reservation.Status != model.ReservationCommitted {
+		for _, l := range reservation.Lines {
+			inventoryChanged(ctx, reservation.TenantID, reservation.SellerID, l.HubCode, l.SKUCode, l.Quantity, reservation.Status)
+		}
+	}
+	return &reservation, result
+}
+
+// holdLines locks each line's inventory row in a fixed order and moves the
+// requested quantity into reserved. Returns errInsufficientInventory with the
+// short lines if anything cannot be held.
+func holdLines(tx *gorm.DB, tenantID, sellerID string, lines []InventoryLine) ([]ShortLine, error) {
+	rows, short, err := lockLines(tx, tenantID, sellerID, lines)
+	if err != nil {
+		return nil, err
+	}
+	if len(short) > 0 {
+		return short, errInsufficientInventory
+	}
+
+	now := time.Now().UTC()
+	for i, l := range lines {
+		if err := tx.Model(&model.Inventory{}).
+			Where("id = ?", rows[i].ID).
+			Updates(map[string]interface{}{
+				"reserved":   gorm.Expr("reserved + ?", l.Quantity),
+				"updated_at": now,
+			}).Error; err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// settleLines takes a reservation's lines out of reserved and, when commit is
+// set, out of on-hand stock as well, recording the order_consume movements.
+func settleLines(tx *gorm.DB, reservation *model.Reservation, commit bool) error {
+	now := time.Now().UTC()
+	for _, l := range reservation.Lines {
+		var row model.Inventory
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("tenant_id = ? AND seller_id = ? AND hub_code = ? AND sku_code = ?", reservation.TenantID, reservation.SellerID, l.HubCode, l.SKUCode).
+			Order("id").
+			First(&row).Error; err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{
+			"reserved":   gorm.Expr("reserved - ?", l.Quantity),
+			"updated_at": now,
+		}
+		if commit {
+			updates["quantity"] = gorm.Expr("quantity - ?", l.Quantity)
+		}
+		if err := tx.Model(&model.Inventory{}).Where("id = ?", row.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+		if commit {
+			row.Quantity -= l.Quantity
+			if err := recordMovement(tx, row, -l.Quantity, model.MovementOrderConsume, reservation.ReferenceID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package controllers
+
+import (
+	"testing"
+
+	"ims/model"
+)
+
+func TestSameLines(t *testing.T) {
+	held := []model.ReservationLine{
+		{HubCode: "H1", SKUCode: "A", Quantity: 2},
+		{HubCode: "H1", SKUCode: "B", Quantity: 1},
+	}
+
+	tests := []struct {
+		name  string
+		lines []InventoryLine
+		want  bool
+	}{
+		{"same lines", []InventoryLine{{HubCode: "H1", SKUCode: "A", Quantity: 2}, {HubCode: "H1", SKUCode: "B", Quantity: 1}}, true},
+		{"same after merging", mergeLines([]InventoryLine{{HubCode: "H1", SKUCode: "B", Quantity: 1}, {HubCode: "H1", SKUCode: "A", Quantity: 1}, {HubCode: "H1", SKUCode: "A", Quantity: 1}}), true},
+		{"other quantity", []InventoryLine{{HubCode: "H1", SKUCode: "A", Quantity: 3}, {HubCode: "H1", SKUCode: "B", Quantity: 1}}, false},
+		{"other hub", []InventoryLine{{HubCode: "H2", SKUCode: "A", Quantity: 2}, {HubCode: "H2", SKUCode: "B", Quantity: 1}}, false},
+		{"fewer lines", []InventoryLine{{HubCode: "H1", SKUCode: "A", Quantity: 2}}, false},
+		{"no lines", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sameLines(held, tt.lines); got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
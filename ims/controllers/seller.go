@@ -25,6 +25,10 @@ func CreateSeller(c *gin.Context) {
 	seller.UpdatedAt = now
 
 	db := pr.DB.GetMasterDB(c.Request.Context())
+	if !requireParent(c, db, "error.tenant_not_found", &model.Tenant{}, "tenant_id = ?", seller.TenantID) {
+		return
+	}
+
 	if err := db.Create(&seller).Error; err != nil {
 		log.DefaultLogger().Errorf("CreateSeller DB error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.Translate(c, "error.create_seller_failed")})
@@ -60,11 +64,21 @@ func UpdateSeller(c *gin.Context) {
 		return
 	}
 
+	oldTenantID, oldSellerID := seller.TenantID, seller.SellerID
 	if err := c.ShouldBindJSON(&seller); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Translate(c, "error.invalid_request")})
 		return
 	}
 
+	if seller.TenantID != oldTenantID || seller.SellerID != oldSellerID {
+		if !requireNoDependents(c, db, sellerDependents(oldTenantID, oldSellerID)...) {
+			return
+		}
+		if !requireParent(c, db, "error.tenant_not_found", &model.Tenant{}, "tenant_id = ?", seller.TenantID) {
+			return
+		}
+	}
+
 	seller.UpdatedAt = time.Now().UTC()
 
 	if err := db.Save(&seller).Error; err != nil {
@@ -81,7 +95,17 @@ func DeleteSeller(c *gin.Context) {
 	id := c.Param("id")
 
 	db := pr.DB.GetMasterDB(c.Request.Context())
-	if err := db.Delete(&model.Seller{}, "id = ?", id).Error; err != nil {
+	var seller model.Seller
+	if err := db.First(&seller, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.Translate(c, "error.seller_not_found")})
+		return
+	}
+
+	if !requireNoDependents(c, db, sellerDependents(seller.TenantID, seller.SellerID)...) {
+		return
+	}
+
+	if err := db.Delete(&seller).Error; err != nil {
 		log.DefaultLogger().Errorf("DeleteSeller DB error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.Translate(c, "error.delete_seller_failed")})
 		return
@@ -103,3 +127,12 @@ func ListSellers(c *gin.Context) {
 
 	c.JSON(http.StatusOK, sellers)
 }
+
+// sellerDependents are the rows that keep a seller from being deleted
+func sellerDependents(tenantID, sellerID string) []dependent {
+	scope := []interface{}{tenantID, sellerID}
+	return []dependent{
+		{name: "hubs", model: &model.Hub{}, query: "tenant_id = ? AND seller_id = ?", args: scope},
+		{name: "skus", model: &model.SKU{}, query: "tenant_id = ? AND seller_id = ?", args: scope},
+	}
+}
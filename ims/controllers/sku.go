@@ -25,12 +25,20 @@ func CreateSKU(c *gin.Context) {
 	sku.UpdatedAt = now
 
 	db := pr.DB.GetMasterDB(c.Request.Context())
+	if !requireParent(c, db, "error.seller_not_found", &model.Seller{}, "tenant_id = ? AND seller_id = ?", sku.TenantID, sku.SellerID) {
+		return
+	}
+
 	if err := db.Create(&sku).Error; err != nil {
 		log.DefaultLogger().Errorf("CreateSKU DB error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.Translate(c, "error.create_sku_failed")})
 		return
 	}
 
+	// the code may be negatively cached from an earlier lookup
+	invalidateCache(c.Request.Context(), skuCodeKey(sku.TenantID, sku.SellerID, sku.SKUCode))
+	notifyWebhooks(c.Request.Context(), sku.TenantID, model.EventSKUCreated, sku)
+
 	c.JSON(http.StatusCreated, sku)
 }
 
@@ -60,11 +68,22 @@ func UpdateSKU(c *gin.Context) {
 		return
 	}
 
+	oldKey := skuCodeKey(sku.TenantID, sku.SellerID, sku.SKUCode)
+	old := sku
 	if err := c.ShouldBindJSON(&sku); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Translate(c, "error.invalid_request")})
 		return
 	}
 
+	if sku.TenantID != old.TenantID || sku.SellerID != old.SellerID {
+		if !requireNoDependents(c, db, skuDependents(old)...) {
+			return
+		}
+		if !requireParent(c, db, "error.seller_not_found", &model.Seller{}, "tenant_id = ? AND seller_id = ?", sku.TenantID, sku.SellerID) {
+			return
+		}
+	}
+
 	sku.UpdatedAt = time.Now().UTC()
 
 	if err := db.Save(&sku).Error; err != nil {
@@ -73,6 +92,9 @@ func UpdateSKU(c *gin.Context) {
 		return
 	}
 
+	invalidateCache(c.Request.Context(), oldKey, skuCodeKey(sku.TenantID, sku.SellerID, sku.SKUCode))
+	notifyWebhooks(c.Request.Context(), sku.TenantID, model.EventSKUUpdated, sku)
+
 	c.JSON(http.StatusOK, sku)
 }
 
@@ -81,12 +103,25 @@ func DeleteSKU(c *gin.Context) {
 	id := c.Param("id")
 
 	db := pr.DB.GetMasterDB(c.Request.Context())
-	if err := db.Delete(&model.SKU{}, "id = ?", id).Error; err != nil {
+	var sku model.SKU
+	if err := db.First(&sku, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.Translate(c, "error.sku_not_found")})
+		return
+	}
+
+	if !requireNoDependents(c, db, skuDependents(sku)...) {
+		return
+	}
+
+	if err := db.Delete(&sku).Error; err != nil {
 		log.DefaultLogger().Errorf("DeleteSKU DB error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.Translate(c, "error.delete_sku_failed")})
 		return
 	}
 
+	invalidateCache(c.Request.Context(), skuCodeKey(sku.TenantID, sku.SellerID, sku.SKUCode))
+	notifyWebhooks(c.Request.Context(), sku.TenantID, model.EventSKUDeleted, sku)
+
 	c.Status(http.StatusNoContent)
 }
 
@@ -104,16 +139,34 @@ func ListSKUs(c *gin.Context) {
 	c.JSON(http.StatusOK, skus)
 }
 
+// GetSKUByCode handles GET /skus/code/:sku_code?tenant_id=...&seller_id=...
 func GetSKUByCode(c *gin.Context) {
 	skuCode := c.Param("sku_code")
-	var sku model.SKU
+	tenantID := c.Query("tenant_id")
+	sellerID := c.Query("seller_id")
 
+	if tenantID == "" || sellerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Translate(c, "error.invalid_request")})
+		return
+	}
+
+	var sku model.SKU
 	db := pr.DB.GetSlaveDB(c.Request.Context())
-	if err := db.Where("sku_code = ?", skuCode).First(&sku).Error; err != nil {
+	if err := cachedLookup(c.Request.Context(), cacheKindSKU, skuCodeKey(tenantID, sellerID, skuCode), &sku, func() error {
+		return db.Where("tenant_id = ? AND seller_id = ? AND sku_code = ?", tenantID, sellerID, skuCode).First(&sku).Error
+	}); err != nil {
 		log.DefaultLogger().Errorf("GetSKUByCode DB error: %v", err)
 		c.JSON(http.StatusNotFound, gin.H{"error": i18n.Translate(c, "error.sku_not_found")})
 		return
 	}
 
 	c.JSON(http.StatusOK, sku)
-}
\ No newline at end of file
+}
+
+// skuDependents are the rows that keep a SKU from being deleted or moved to
+// another seller. A code rename is carried over to inventory by the foreign key.
+func skuDependents(sku model.SKU) []dependent {
+	return []dependent{
+		{name: "inventory", model: &model.Inventory{}, query: "tenant_id = ? AND seller_id = ? AND sku_code = ?", args: []interface{}{sku.TenantID, sku.SellerID, sku.SKUCode}},
+	}
+}
@@ -60,11 +60,16 @@ func UpdateTenant(c *gin.Context) {
 		return
 	}
 
+	oldTenantID := tenant.TenantID
 	if err := c.ShouldBindJSON(&tenant); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Translate(c, "error.invalid_request")})
 		return
 	}
 
+	if tenant.TenantID != oldTenantID && !requireNoDependents(c, db, tenantDependents(oldTenantID)...) {
+		return
+	}
+
 	tenant.UpdatedAt = time.Now().UTC()
 
 	if err := db.Save(&tenant).Error; err != nil {
@@ -81,7 +86,17 @@ func DeleteTenant(c *gin.Context) {
 	id := c.Param("id")
 
 	db := pr.DB.GetMasterDB(c.Request.Context())
-	if err := db.Delete(&model.Tenant{}, "id = ?", id).Error; err != nil {
+	var tenant model.Tenant
+	if err := db.First(&tenant, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.Translate(c, "error.tenant_not_found")})
+		return
+	}
+
+	if !requireNoDependents(c, db, tenantDependents(tenant.TenantID)...) {
+		return
+	}
+
+	if err := db.Delete(&tenant).Error; err != nil {
 		log.DefaultLogger().Errorf("DeleteTenant DB error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.Translate(c, "error.delete_tenant_failed")})
 		return
@@ -103,3 +118,10 @@ func ListTenants(c *gin.Context) {
 
 	c.JSON(http.StatusOK, tenants)
 }
+
+// tenantDependents are the rows that keep a tenant from being deleted
+func tenantDependents(tenantID string) []dependent {
+	return []dependent{
+		{name: "sellers", model: &model.Seller{}, query: "tenant_id = ?", args: []interface{}{tenantID}},
+	}
+}
@@ -0,0 +1,285 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"ims/model"
+	pr "ims/postgres"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omniful/go_commons/i18n"
+	"github.com/omniful/go_commons/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errTransferNotFound = errors.New("transfer not found")
+	errTransferState    = errors.New("transfer cannot move to that status")
+)
+
+// TransferLineRequest is one SKU quantity of a transfer
+type TransferLineRequest struct {
+	SKUCode  string `json:"sku_code" binding:"required"`
+	Quantity int64  `json:"quantity" binding:"required,gt=0"`
+}
+
+// CreateTransferRequest is the body of POST /transfers
+type CreateTransferRequest struct {
+	TenantID       string                `json:"tenant_id" binding:"required"`
+	SellerID       string                `json:"seller_id" binding:"required"`
+	SourceHub      string                `json:"source_hub" binding:"required"`
+	DestinationHub string                `json:"destination_hub" binding:"required,nefield=SourceHub"`
+	ReferenceID    string                `json:"reference_id"`
+	Lines          []TransferLineRequest `json:"lines" binding:"required,min=1,dive"`
+}
+
+// CreateTransfer handles POST /transfers. The transfer starts out created;
+// no stock moves until it is dispatched.
+func CreateTransfer(c *gin.Context) {
+	var req CreateTransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Translate(c, "error.invalid_request")})
+		return
+	}
+
+	db := pr.DB.GetMasterDB(c.Request.Context())
+	for _, hub := range []string{req.SourceHub, req.DestinationHub} {
+		if !requireParent(c, db, "error.hub_not_found", &model.Hub{}, "tenant_id = ? AND seller_id = ? AND hub_code = ?", req.TenantID, req.SellerID, hub) {
+			return
+		}
+	}
+
+	// the same SKU listed twice is one line
+	var lines []InventoryLine
+	for _, l := range req.Lines {
+		lines = append(lines, InventoryLine{HubCode: req.SourceHub, SKUCode: l.SKUCode, Quantity: l.Quantity})
+	}
+	lines = mergeLines(lines)
+
+	now := time.Now().UTC()
+	transfer := model.Transfer{
+		TenantID:       req.TenantID,
+		SellerID:       req.SellerID,
+		SourceHub:      req.SourceHub,
+		DestinationHub: req.DestinationHub,
+		ReferenceID:    req.ReferenceID,
+		Status:         model.TransferCreated,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	for _, l := range lines {
+		if !requireParent(c, db, "error.sku_not_found", &model.SKU{}, "tenant_id = ? AND seller_id = ? AND sku_code = ?", req.TenantID, req.SellerID, l.SKUCode) {
+			return
+		}
+		transfer.Lines = append(transfer.Lines, model.TransferLine{SKUCode: l.SKUCode, Quantity: l.Quantity})
+	}
+
+	if err := db.Create(&transfer).Error; err != nil {
+		log.DefaultLogger().Errorf("CreateTransfer DB error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.Translate(c, "error.create_transfer_failed")})
+		return
+	}
+
+	log.Infof("Transfer created: id=%s %s -> %s lines=%d", transfer.ID, transfer.SourceHub, transfer.DestinationHub, len(transfer.Lines))
+	c.JSON(http.StatusCreated, transfer)
+}
+
+// GetTransfer handles GET /transfers/:id
+func GetTransfer(c *gin.Context) {
+	id := c.Param("id")
+	var transfer model.Transfer
+
+	db := pr.DB.GetSlaveDB(c.Request.Context())
+	if err := db.Preload("Lines").First(&transfer, "id = ?", id).Error; err != nil {
+		log.DefaultLogger().Errorf("GetTransfer DB error: %v", err)
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.Translate(c, "error.transfer_not_found")})
+		return
+	}
+
+	c.JSON(http.StatusOK, transfer)
+}
+
+// ListTransfers handles GET /transfers?tenant_id=&seller_id=&hub_code=.
+// It lists the open (created or dispatched) transfers leaving or arriving at
+// the hub, or those in status when given, and the quantity of each SKU in
+// transit to the hub.
+func ListTransfers(c *gin.Context) {
+	tenantID := c.Query("tenant_id")
+	sellerID := c.Query("seller_id")
+	hubCode := c.Query("hub_code")
+	if tenantID == "" || sellerID == "" || hubCode == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Translate(c, "error.invalid_request")})
+		return
+	}
+
+	statuses := []string{model.TransferCreated, model.TransferDispatched}
+	if status := c.Query("status"); status != "" {
+		statuses = []string{status}
+	}
+
+	var transfers []model.Transfer
+	db := pr.DB.GetSlaveDB(c.Request.Context())
+	if err := db.Preload("Lines").
+		Where("tenant_id = ? AND seller_id = ? AND (source_hub = ? OR destination_hub = ?) AND status IN ?", tenantID, sellerID, hubCode, hubCode, statuses).
+		Order("created_at").
+		Find(&transfers).Error; err != nil {
+		log.DefaultLogger().Errorf("ListTransfers DB error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.Translate(c, "error.list_transfers_failed")})
+		return
+	}
+
+	var inTransit []InventoryLine
+	if err := db.Model(&model.TransferLine{}).
+		Select("transfers.destination_hub AS hub_code, transfer_lines.sku_code, SUM(transfer_lines.quantity) AS quantity").
+		Joins("JOIN transfers ON transfers.id = transfer_lines.transfer_id").
+		Where("transfers.tenant_id = ? AND transfers.seller_id = ? AND transfers.destination_hub = ? AND transfers.status = ?", tenantID, sellerID, hubCode, model.TransferDispatched).
+		Group("transfers.destination_hub, transfer_lines.sku_code").
+		Order("transfer_lines.sku_code").
+		Scan(&inTransit).Error; err != nil {
+		log.DefaultLogger().Errorf("ListTransfers in-transit DB error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.Translate(c, "error.list_transfers_failed")})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"transfers":  transfers,
+		"in_transit": inTransit,
+	})
+}
+
+// DispatchTransfer handles POST /transfers/:id/dispatch.
+// Every line is taken out of the source hub, or none is.
+func DispatchTransfer(c *gin.Context) {
+	moveTransferHandler(c, model.TransferDispatched)
+}
+
+// ReceiveTransfer handles POST /transfers/:id/receive.
+// The dispatched stock is added to the destination hub.
+func ReceiveTransfer(c *gin.Context) {
+	moveTransferHandler(c, model.TransferReceived)
+}
+
+// CancelTransfer handles POST /transfers/:id/cancel.
+// Stock already dispatched goes back to the source hub.
+func CancelTransfer(c *gin.Context) {
+	moveTransferHandler(c, model.TransferCancelled)
+}
+
+func moveTransferHandler(c *gin.Context, target string) {
+	id := c.Param("id")
+
+	transfer, short, err := moveTransfer(c.Request.Context(), id, target, time.Now().UTC())
+
+	switch {
+	case errors.Is(err, errTransferNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.Translate(c, "error.transfer_not_found")})
+		return
+	case errors.Is(err, errTransferState):
+		c.JSON(http.StatusConflict, gin.H{"error": i18n.Translate(c, "error.transfer_state"), "transfer": transfer})
+		return
+	case errors.Is(err, errInsufficientInventory):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":       i18n.Translate(c, "error.insufficient_inventory"),
+			"short_lines": short,
+		})
+		return
+	case err != nil:
+		log.DefaultLogger().Errorf("Transfer %s -> %s DB error: %v", id, target, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.Translate(c, "error.update_transfer_failed")})
+		return
+	}
+
+	log.Infof("Transfer %s is now %s", id, transfer.Status)
+	c.JSON(http.StatusOK, transfer)
+}
+
+// moveTransfer moves a transfer to target and its stock with it, in one
+// transaction. Moving a transfer to the status it is already in is a no-op.
+//
+//	created    -> dispatched: lines leave the source hub
+//	dispatched -> received:   lines arrive at the destination hub
+//	created    -> cancelled:  nothing has moved yet
+//	dispatched -> cancelled:  lines go back to the source hub
+func moveTransfer(ctx context.Context, id, target string, now time.Time) (*model.Transfer, []ShortLine, error) {
+	var transfer model.Transfer
+	var short []ShortLine
+	var taken, returned []ReservedLine
+	var result error
+
+	db := pr.DB.GetMasterDB(ctx)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&transfer, "id = ?", id).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errTransferNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("transfer_id = ?", id).Order("sku_code").Find(&transfer.Lines).Error; err != nil {
+			return err
+		}
+
+		if transfer.Status == target {
+			return nil
+		}
+
+		updates := map[string]interface{}{"status": target, "updated_at": now}
+		switch {
+		case transfer.Status == model.TransferCreated && target == model.TransferDispatched:
+			taken, short, err = decrementLines(tx, transfer.TenantID, transfer.SellerID, model.MovementTransfer, transfer.ID, transferLines(transfer, transfer.SourceHub))
+			if err != nil {
+				return err
+			}
+			transfer.DispatchedAt = &now
+			updates["dispatched_at"] = now
+		case transfer.Status == model.TransferDispatched && target == model.TransferReceived:
+			if returned, _, err = incrementLines(tx, transfer.TenantID, transfer.SellerID, model.MovementTransfer, transfer.ID, transferLines(transfer, transfer.DestinationHub), true); err != nil {
+				return err
+			}
+			transfer.ReceivedAt = &now
+			updates["received_at"] = now
+		case transfer.Status == model.TransferDispatched && target == model.TransferCancelled:
+			if returned, _, err = incrementLines(tx, transfer.TenantID, transfer.SellerID, model.MovementTransfer, transfer.ID, transferLines(transfer, transfer.SourceHub), true); err != nil {
+				return err
+			}
+			fallthrough
+		case transfer.Status == model.TransferCreated && target == model.TransferCancelled:
+			transfer.CancelledAt = &now
+			updates["cancelled_at"] = now
+		default:
+			result = errTransferState
+			return nil
+		}
+
+		transfer.Status = target
+		transfer.UpdatedAt = now
+		return tx.Model(&model.Transfer{}).Where("id = ?", id).Updates(updates).Error
+	})
+	if err != nil {
+		return nil, short, err
+	}
+
+	// Received and returned stock is new availability at its hub; dispatched
+	// stock leaves the source hub
+	for _, l := range returned {
+		inventoryChanged(ctx, transfer.TenantID, transfer.SellerID, l.HubCode, l.SKUCode, l.Quantity, model.InventoryReasonTransfer)
+	}
+	for _, l := range taken {
+		inventoryChanged(ctx, transfer.TenantID, transfer.SellerID, l.HubCode, l.SKUCode, -l.Quantity, model.InventoryReasonTransfer)
+		checkStockLevel(ctx, transfer.TenantID, transfer.SellerID, l.HubCode, l.SKUCode, -l.Quantity)
+	}
+	return &transfer, nil, result
+}
+
+// transferLines are the lines of a transfer at hubCode, in locking order
+func transferLines(transfer model.Transfer, hubCode string) []InventoryLine {
+	lines := make([]InventoryLine, 0, len(transfer.Lines))
+	for _, l := range transfer.Lines {
+		lines = append(lines, InventoryLine{HubCode: hubCode, SKUCode: l.SKUCode, Quantity: l.Quantity})
+	}
+	return mergeLines(lines)
+}
@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"net/http"
+
+	"ims/model"
+	pr "ims/postgres"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omniful/go_commons/i18n"
+	"github.com/omniful/go_commons/log"
+	"gorm.io/gorm"
+)
+
+// ValidateCodesRequest is the body of POST /skus/validate and /hubs/validate
+type ValidateCodesRequest struct {
+	TenantID string   `json:"tenant_id" binding:"required"`
+	SellerID string   `json:"seller_id" binding:"required"`
+	Codes    []string `json:"codes" binding:"required,min=1,max=1000"`
+}
+
+// ValidateCodesResponse splits the requested codes into those that exist for
+// the tenant and seller and those that do not
+type ValidateCodesResponse struct {
+	Valid   []string `json:"valid"`
+	Invalid []string `json:"invalid"`
+}
+
+// ValidateSKUs handles POST /skus/validate
+func ValidateSKUs(c *gin.Context) {
+	validateCodes(c, cacheKindSKU, skuCodeKey, func(db *gorm.DB, tenantID, sellerID string, codes []string) (map[string]interface{}, error) {
+		var skus []model.SKU
+		if err := db.Where("tenant_id = ? AND seller_id = ? AND sku_code IN ?", tenantID, sellerID, codes).Find(&skus).Error; err != nil {
+			return nil, err
+		}
+		out := make(map[string]interface{}, len(skus))
+		for _, sku := range skus {
+			out[sku.SKUCode] = sku
+		}
+		return out, nil
+	})
+}
+
+// ValidateHubs handles POST /hubs/validate
+func ValidateHubs(c *gin.Context) {
+	validateCodes(c, cacheKindHub, hubCodeKey, func(db *gorm.DB, tenantID, sellerID string, codes []string) (map[string]interface{}, error) {
+		var hubs []model.Hub
+		if err := db.Where("tenant_id = ? AND seller_id = ? AND hub_code IN ?", tenantID, sellerID, codes).Find(&hubs).Error; err != nil {
+			return nil, err
+		}
+		out := make(map[string]interface{}, len(hubs))
+		for _, hub := range hubs {
+			out[hub.HubCode] = hub
+		}
+		return out, nil
+	})
+}
+
+// validateCodes resolves every requested code through the code cache, with a
+// single query for the codes Redis does not know. Both the cache keys and the
+// query are scoped to the requesting tenant and seller.
+func validateCodes(c *gin.Context, kind string, keyOf func(tenantID, sellerID, code string) string, load func(db *gorm.DB, tenantID, sellerID string, codes []string) (map[string]interface{}, error)) {
+	var req ValidateCodesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Translate(c, "error.invalid_request")})
+		return
+	}
+
+	codes := make([]string, 0, len(req.Codes))
+	seen := make(map[string]bool, len(req.Codes))
+	for _, code := range req.Codes {
+		if !seen[code] {
+			seen[code] = true
+			codes = append(codes, code)
+		}
+	}
+
+	db := pr.DB.GetSlaveDB(c.Request.Context())
+	scopedKey := func(code string) string { return keyOf(req.TenantID, req.SellerID, code) }
+	found, err := cachedLookupMany(c.Request.Context(), kind, scopedKey, codes, func(missing []string) (map[string]interface{}, error) {
+		return load(db, req.TenantID, req.SellerID, missing)
+	})
+	if err != nil {
+		log.DefaultLogger().Errorf("Validate %s codes DB error: %v", kind, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.Translate(c, "error.validate_codes_failed")})
+		return
+	}
+
+	resp := ValidateCodesResponse{Valid: []string{}, Invalid: []string{}}
+	for _, code := range codes {
+		if _, ok := found[code]; ok {
+			resp.Valid = append(resp.Valid, code)
+		} else {
+			resp.Invalid = append(resp.Invalid, code)
+		}
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
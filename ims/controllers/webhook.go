@@ -15,7 +15,7 @@ import (
 // CreateWebhook handles POST /webhooks
 func CreateWebhook(c *gin.Context) {
 	var webhook model.WebhookRegistration
-	if err := c.ShouldBindJSON(&webhook); err != nil {
+	if err := c.ShouldBindJSON(&webhook); err != nil || !model.IsValidWebhookEvent(webhook.EventType) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Translate(c, "error.invalid_request")})
 		return
 	}
@@ -58,7 +58,7 @@ func UpdateWebhook(c *gin.Context) {
 		return
 	}
 
-	if err := c.ShouldBindJSON(&webhook); err != nil {
+	if err := c.ShouldBindJSON(&webhook); err != nil || !model.IsValidWebhookEvent(webhook.EventType) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Translate(c, "error.invalid_request")})
 		return
 	}
@@ -87,12 +87,19 @@ func DeleteWebhook(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
-// ListWebhooks handles GET /webhooks
+// ListWebhooks handles GET /webhooks, optionally filtered by tenant_id and event_type
 func ListWebhooks(c *gin.Context) {
 	var webhooks []model.WebhookRegistration
 
 	db := pr.DB.GetSlaveDB(c.Request.Context())
-	if err := db.Find(&webhooks).Error; err != nil {
+	q := db.Order("created_at")
+	if tenantID := c.Query("tenant_id"); tenantID != "" {
+		q = q.Where("tenant_id = ?", tenantID)
+	}
+	if eventType := c.Query("event_type"); eventType != "" {
+		q = q.Where("event_type = ?", eventType)
+	}
+	if err := q.Find(&webhooks).Error; err != nil {
 		log.DefaultLogger().Errorf("ListWebhooks DB error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.Translate(c, "error.list_webhooks_failed")})
 		return
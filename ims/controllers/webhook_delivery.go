@@ -0,0 +1,363 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"ims/model"
+	pr "ims/postgres"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omniful/go_commons/config"
+	"github.com/omniful/go_commons/i18n"
+	"github.com/omniful/go_commons/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultWebhookTimeout      = 5 * time.Second
+	defaultWebhookMaxAttempts  = 5
+	defaultWebhookRetryBackoff = 30 * time.Second
+	maxWebhookBackoff          = 6 * time.Hour
+)
+
+// Headers sent with every delivery. The delivery id is the same on every
+// attempt, so receivers can drop the ones they already handled.
+const (
+	HeaderWebhookEvent    = "X-Webhook-Event"
+	HeaderWebhookDelivery = "X-Webhook-Delivery"
+)
+
+var errDeliveryNotFailed = errors.New("delivery has not failed")
+
+// webhookSettings are the delivery settings from the webhooks config block
+type webhookSettings struct {
+	timeout     time.Duration
+	maxAttempts int
+	backoff     time.Duration
+}
+
+func loadWebhookSettings(ctx context.Context) webhookSettings {
+	s := webhookSettings{
+		timeout:     config.GetDuration(ctx, "webhooks.timeout"),
+		maxAttempts: config.GetInt(ctx, "webhooks.max_attempts"),
+		backoff:     config.GetDuration(ctx, "webhooks.retry_backoff"),
+	}
+	if s.timeout <= 0 {
+		s.timeout = defaultWebhookTimeout
+	}
+	if s.maxAttempts <= 0 {
+		s.maxAttempts = defaultWebhookMaxAttempts
+	}
+	if s.backoff <= 0 {
+		s.backoff = defaultWebhookRetryBackoff
+	}
+	return s
+}
+
+// lease keeps a delivery that is being attempted away from the retry sweep
+func (s webhookSettings) lease(now time.Time) time.Time {
+	return now.Add(2 * s.timeout)
+}
+
+// retryAt doubles the backoff after every failed attempt
+func (s webhookSettings) retryAt(now time.Time, attempts int) time.Time {
+	wait := s.backoff
+	for i := 1; i < attempts && wait < maxWebhookBackoff; i++ {
+		wait *= 2
+	}
+	if wait > maxWebhookBackoff {
+		wait = maxWebhookBackoff
+	}
+	return now.Add(wait)
+}
+
+// notifyWebhooks logs a delivery of the event for every active registration
+// of the tenant for eventType and makes the first attempt in the background.
+// Failed attempts are retried by the webhook sweeper. Nothing here fails the
+// request.
+func notifyWebhooks(ctx context.Context, tenantID, eventType string, payload interface{}) {
+	var webhooks []model.WebhookRegistration
+	db := pr.DB.GetMasterDB(ctx)
+	if err := db.Where("tenant_id = ? AND event_type = ? AND is_active", tenantID, eventType).Find(&webhooks).Error; err != nil {
+		log.DefaultLogger().Errorf("Failed to load webhooks for %s: %v", eventType, err)
+		return
+	}
+	if len(webhooks) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"tenant_id": tenantID,
+		"event":     eventType,
+		"data":      payload,
+	})
+	if err != nil {
+		log.DefaultLogger().Errorf("Failed to marshal %s webhook payload: %v", eventType, err)
+		return
+	}
+
+	settings := loadWebhookSettings(ctx)
+	now := time.Now().UTC()
+	lease := settings.lease(now)
+	deliveries := make([]model.WebhookDelivery, 0, len(webhooks))
+	for _, wh := range webhooks {
+		deliveries = append(deliveries, model.WebhookDelivery{
+			WebhookID:     wh.ID,
+			TenantID:      tenantID,
+			EventType:     eventType,
+			URL:           wh.URL,
+			Payload:       string(body),
+			Status:        model.DeliveryPending,
+			NextAttemptAt: &lease,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		})
+	}
+	if err := db.Create(&deliveries).Error; err != nil {
+		log.DefaultLogger().Errorf("Failed to log %s webhook deliveries: %v", eventType, err)
+		return
+	}
+
+	// deliveries outlive the request
+	ctx = context.WithoutCancel(ctx)
+	for _, d := range deliveries {
+		go attemptDelivery(ctx, settings, d)
+	}
+}
+
+// attemptDelivery posts a delivery once and records the outcome
+func attemptDelivery(ctx context.Context, settings webhookSettings, d model.WebhookDelivery) {
+	updates := deliver(ctx, settings, d)
+
+	db := pr.DB.GetMasterDB(ctx)
+	if err := db.Model(&model.WebhookDelivery{}).Where("id = ?", d.ID).Updates(updates).Error; err != nil {
+		log.DefaultLogger().Errorf("Failed to record webhook delivery %d: %v", d.ID, err)
+	}
+}
+
+// deliver posts a delivery once and returns the column updates for the
+// outcome: delivered on a 2xx answer, otherwise another attempt after the
+// backoff, or failed once the attempts are used up
+func deliver(ctx context.Context, settings webhookSettings, d model.WebhookDelivery) map[string]interface{} {
+	code, err := postWebhook(ctx, settings.timeout, d.URL, d)
+	now := time.Now().UTC()
+	d.Attempts++
+
+	updates := map[string]interface{}{
+		"attempts":         d.Attempts,
+		"last_status_code": code,
+		"last_error":       "",
+		"updated_at":       now,
+	}
+	switch {
+	case err == nil:
+		updates["status"] = model.DeliveryDelivered
+		updates["delivered_at"] = now
+		updates["next_attempt_at"] = nil
+		log.Infof("Webhook delivery %d (%s) delivered to %s", d.ID, d.EventType, d.URL)
+	case d.Attempts >= settings.maxAttempts:
+		updates["status"] = model.DeliveryFailed
+		updates["last_error"] = err.Error()
+		updates["next_attempt_at"] = nil
+		log.DefaultLogger().Errorf("Webhook delivery %d (%s) failed after %d attempts: %v", d.ID, d.EventType, d.Attempts, err)
+	default:
+		updates["last_error"] = err.Error()
+		updates["next_attempt_at"] = settings.retryAt(now, d.Attempts)
+		log.DefaultLogger().Warnf("Webhook delivery %d (%s) attempt %d failed, will retry: %v", d.ID, d.EventType, d.Attempts, err)
+	}
+	return updates
+}
+
+// postWebhook sends the delivery's body and returns the HTTP status, with an
+// error for anything but a 2xx answer
+func postWebhook(ctx context.Context, timeout time.Duration, url string, d model.WebhookDelivery) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader([]byte(d.Payload)))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderWebhookEvent, d.EventType)
+	req.Header.Set(HeaderWebhookDelivery, strconv.FormatInt(d.ID, 10))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("answered %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// RetryDueWebhookDeliveries makes the next attempt of every pending delivery
+// that is due, at most limit per call. Each one is leased first, so two
+// sweeps never send the same attempt. It is driven by the webhook sweeper.
+func RetryDueWebhookDeliveries(ctx context.Context, limit int) (int, error) {
+	return retryDueDeliveries(ctx, loadWebhookSettings(ctx), limit)
+}
+
+func retryDueDeliveries(ctx context.Context, settings webhookSettings, limit int) (int, error) {
+	now := time.Now().UTC()
+
+	var due []model.WebhookDelivery
+	db := pr.DB.GetMasterDB(ctx)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", model.DeliveryPending, now).
+			Order("next_attempt_at").
+			Limit(limit).
+			Find(&due).Error; err != nil {
+			return err
+		}
+		if len(due) == 0 {
+			return nil
+		}
+
+		ids := make([]int64, 0, len(due))
+		for _, d := range due {
+			ids = append(ids, d.ID)
+		}
+		return tx.Model(&model.WebhookDelivery{}).Where("id IN ?", ids).
+			Updates(map[string]interface{}{"next_attempt_at": settings.lease(now), "updated_at": now}).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// in parallel, so every attempt finishes within its lease
+	var wg sync.WaitGroup
+	for _, d := range due {
+		wg.Add(1)
+		go func(d model.WebhookDelivery) {
+			defer wg.Done()
+			attemptDelivery(ctx, settings, d)
+		}(d)
+	}
+	wg.Wait()
+	return len(due), nil
+}
+
+const (
+	defaultDeliveriesLimit = 100
+	maxDeliveriesLimit     = 1000
+)
+
+// ListWebhookDeliveries handles GET /webhooks/:id/deliveries, the delivery
+// log of a registration, newest first. It can be filtered by status and
+// pages with limit (default 100, max 1000) and before_id.
+func ListWebhookDeliveries(c *gin.Context) {
+	limit := defaultDeliveriesLimit
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Translate(c, "error.invalid_request")})
+			return
+		}
+		if n < maxDeliveriesLimit {
+			limit = n
+		} else {
+			limit = maxDeliveriesLimit
+		}
+	}
+
+	db := pr.DB.GetSlaveDB(c.Request.Context())
+	q := db.Where("webhook_id = ?", c.Param("id"))
+	if status := c.Query("status"); status != "" {
+		q = q.Where("status = ?", status)
+	}
+	if raw := c.Query("before_id"); raw != "" {
+		beforeID, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Translate(c, "error.invalid_request")})
+			return
+		}
+		q = q.Where("id < ?", beforeID)
+	}
+
+	deliveries := []model.WebhookDelivery{}
+	if err := q.Order("id DESC").Limit(limit).Find(&deliveries).Error; err != nil {
+		log.DefaultLogger().Errorf("ListWebhookDeliveries DB error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.Translate(c, "error.list_webhook_deliveries_failed")})
+		return
+	}
+
+	resp := gin.H{"deliveries": deliveries}
+	if len(deliveries) == limit {
+		resp["next_before_id"] = deliveries[len(deliveries)-1].ID
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// RetryWebhookDelivery handles POST /webhooks/:id/deliveries/:delivery_id/retry.
+// A failed delivery gets one more attempt right away.
+func RetryWebhookDelivery(c *gin.Context) {
+	ctx := c.Request.Context()
+	delivery, err := retryFailedDelivery(ctx, loadWebhookSettings(ctx), c.Param("id"), c.Param("delivery_id"))
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.Translate(c, "error.webhook_delivery_not_found")})
+		return
+	case errors.Is(err, errDeliveryNotFailed):
+		c.JSON(http.StatusConflict, gin.H{"error": i18n.Translate(c, "error.webhook_delivery_not_failed"), "delivery": delivery})
+		return
+	case err != nil:
+		log.DefaultLogger().Errorf("RetryWebhookDelivery DB error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.Translate(c, "error.update_webhook_failed")})
+		return
+	}
+	c.JSON(http.StatusOK, delivery)
+}
+
+// retryFailedDelivery moves a failed delivery of the webhook back to pending,
+// attempts it once and returns it as it stands afterwards
+func retryFailedDelivery(ctx context.Context, settings webhookSettings, webhookID, deliveryID string) (model.WebhookDelivery, error) {
+	now := time.Now().UTC()
+
+	var delivery model.WebhookDelivery
+	db := pr.DB.GetMasterDB(ctx)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&delivery, "id = ? AND webhook_id = ?", deliveryID, webhookID).Error; err != nil {
+			return err
+		}
+		if delivery.Status != model.DeliveryFailed {
+			return errDeliveryNotFailed
+		}
+
+		// its attempts are used up, so a failure marks it failed again
+		lease := settings.lease(now)
+		delivery.Status = model.DeliveryPending
+		delivery.NextAttemptAt = &lease
+		return tx.Model(&model.WebhookDelivery{}).Where("id = ?", delivery.ID).
+			Updates(map[string]interface{}{
+				"status":          delivery.Status,
+				"next_attempt_at": lease,
+				"updated_at":      now,
+			}).Error
+	})
+	if err != nil {
+		return delivery, err
+	}
+
+	attemptDelivery(ctx, settings, delivery)
+	if err := db.First(&delivery, "id = ?", delivery.ID).Error; err != nil {
+		log.DefaultLogger().Errorf("RetryWebhookDelivery reload error: %v", err)
+	}
+	return delivery, nil
+}
@@ -0,0 +1,142 @@
+//go:build integration
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"ims/model"
+	pr "ims/postgres"
+
+	"github.com/omniful/go_commons/db/sql/migration"
+	"github.com/omniful/go_commons/db/sql/postgres"
+	"gorm.io/gorm"
+)
+
+// testDB connects pr.DB to the Postgres named by the IMS_TEST_POSTGRES_*
+// variables and migrates it. The tests in this file only build with the
+// integration tag and are skipped when IMS_TEST_POSTGRES_HOST is not set.
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	host := os.Getenv("IMS_TEST_POSTGRES_HOST")
+	if host == "" {
+		t.Skip("IMS_TEST_POSTGRES_HOST is not set")
+	}
+
+	master := postgres.DBConfig{
+		Host:     host,
+		Port:     os.Getenv("IMS_TEST_POSTGRES_PORT"),
+		Username: os.Getenv("IMS_TEST_POSTGRES_USER"),
+		Password: os.Getenv("IMS_TEST_POSTGRES_PASSWORD"),
+		Dbname:   os.Getenv("IMS_TEST_POSTGRES_DB"),
+	}
+	var replicas []postgres.DBConfig
+	pr.DB = postgres.InitializeDBInstance(master, &replicas)
+
+	dbURL := migration.BuildSQLDBURL(master.Host, master.Port, master.Dbname, master.Username, master.Password)
+	migration.Execute("file://../sql/migration", dbURL, "up", "")
+
+	return pr.DB.GetMasterDB(context.Background())
+}
+
+// createDelivery saves a registration for url and one delivery to it
+func createDelivery(t *testing.T, db *gorm.DB, url string, d model.WebhookDelivery) model.WebhookDelivery {
+	t.Helper()
+	wh := model.WebhookRegistration{TenantID: "test-tenant", URL: url, EventType: "sku.created", IsActive: true}
+	if err := db.Create(&wh).Error; err != nil {
+		t.Fatalf("create webhook: %v", err)
+	}
+	t.Cleanup(func() { db.Delete(&model.WebhookRegistration{}, "id = ?", wh.ID) })
+
+	d.WebhookID = wh.ID
+	d.TenantID = wh.TenantID
+	d.EventType = wh.EventType
+	d.URL = url
+	d.Payload = `{}`
+	if err := db.Create(&d).Error; err != nil {
+		t.Fatalf("create delivery: %v", err)
+	}
+	return d
+}
+
+func TestRetryFailedDelivery(t *testing.T) {
+	db := testDB(t)
+	sink := newWebhookSink(t, http.StatusOK)
+	settings := testWebhookSettings()
+	ctx := context.Background()
+
+	failed := createDelivery(t, db, sink.URL, model.WebhookDelivery{Status: model.DeliveryFailed, Attempts: settings.maxAttempts, LastStatusCode: 500, LastError: "answered 500"})
+	id := strconv.FormatInt(failed.ID, 10)
+
+	got, err := retryFailedDelivery(ctx, settings, failed.WebhookID, id)
+	if err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if got.Status != model.DeliveryDelivered || got.Attempts != settings.maxAttempts+1 || got.DeliveredAt == nil {
+		t.Fatalf("got status=%s attempts=%d delivered_at=%v, want delivered on the manual attempt", got.Status, got.Attempts, got.DeliveredAt)
+	}
+	if n := len(sink.received()); n != 1 {
+		t.Fatalf("sink got %d requests, want 1", n)
+	}
+
+	// only failed deliveries can be retried
+	if _, err := retryFailedDelivery(ctx, settings, failed.WebhookID, id); !errors.Is(err, errDeliveryNotFailed) {
+		t.Fatalf("retrying a delivered delivery: got %v, want errDeliveryNotFailed", err)
+	}
+	// and only under their own webhook
+	other := createDelivery(t, db, sink.URL, model.WebhookDelivery{Status: model.DeliveryFailed})
+	if _, err := retryFailedDelivery(ctx, settings, other.WebhookID, id); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("retrying under another webhook: got %v, want gorm.ErrRecordNotFound", err)
+	}
+}
+
+func TestRetryFailedDeliveryFailsAgain(t *testing.T) {
+	db := testDB(t)
+	sink := newWebhookSink(t, http.StatusBadGateway)
+	settings := testWebhookSettings()
+
+	failed := createDelivery(t, db, sink.URL, model.WebhookDelivery{Status: model.DeliveryFailed, Attempts: settings.maxAttempts})
+
+	got, err := retryFailedDelivery(context.Background(), settings, failed.WebhookID, strconv.FormatInt(failed.ID, 10))
+	if err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if got.Status != model.DeliveryFailed || got.LastStatusCode != http.StatusBadGateway || got.NextAttemptAt != nil {
+		t.Fatalf("got status=%s code=%d next=%v, want failed again with the 502", got.Status, got.LastStatusCode, got.NextAttemptAt)
+	}
+}
+
+func TestRetryDueDeliveries(t *testing.T) {
+	db := testDB(t)
+	sink := newWebhookSink(t, http.StatusInternalServerError)
+	settings := testWebhookSettings()
+
+	past := time.Now().UTC().Add(-time.Minute)
+	future := time.Now().UTC().Add(time.Hour)
+	due := createDelivery(t, db, sink.URL, model.WebhookDelivery{Status: model.DeliveryPending, NextAttemptAt: &past})
+	notDue := createDelivery(t, db, sink.URL, model.WebhookDelivery{Status: model.DeliveryPending, NextAttemptAt: &future})
+
+	if _, err := retryDueDeliveries(context.Background(), settings, 1000); err != nil {
+		t.Fatalf("sweep: %v", err)
+	}
+
+	var got model.WebhookDelivery
+	if err := db.First(&got, "id = ?", due.ID).Error; err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if got.Status != model.DeliveryPending || got.Attempts != 1 || got.NextAttemptAt == nil || !got.NextAttemptAt.After(time.Now().UTC()) {
+		t.Fatalf("due delivery: got status=%s attempts=%d next=%v, want pending with the next attempt backed off", got.Status, got.Attempts, got.NextAttemptAt)
+	}
+	if err := db.First(&got, "id = ?", notDue.ID).Error; err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if got.Attempts != 0 {
+		t.Fatalf("delivery not yet due was attempted %d times", got.Attempts)
+	}
+}
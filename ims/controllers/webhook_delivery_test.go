@@ -0,0 +1,173 @@
+package controllers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"ims/model"
+)
+
+// webhookSink is a receiver that answers with codes in turn, repeating the
+// last one, and keeps every request it was sent
+type webhookSink struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	codes    []int
+	requests []sinkRequest
+}
+
+type sinkRequest struct {
+	event    string
+	delivery string
+	body     string
+}
+
+func newWebhookSink(t *testing.T, codes ...int) *webhookSink {
+	s := &webhookSink{codes: codes}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		s.mu.Lock()
+		code := s.codes[len(s.codes)-1]
+		if n := len(s.requests); n < len(s.codes) {
+			code = s.codes[n]
+		}
+		s.requests = append(s.requests, sinkRequest{
+			event:    r.Header.Get(HeaderWebhookEvent),
+			delivery: r.Header.Get(HeaderWebhookDelivery),
+			body:     string(body),
+		})
+		s.mu.Unlock()
+
+		w.WriteHeader(code)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *webhookSink) received() []sinkRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]sinkRequest(nil), s.requests...)
+}
+
+func testWebhookSettings() webhookSettings {
+	return webhookSettings{timeout: time.Second, maxAttempts: 3, backoff: time.Minute}
+}
+
+// apply copies the columns deliver set onto d, as attemptDelivery writes them
+func apply(d model.WebhookDelivery, updates map[string]interface{}) model.WebhookDelivery {
+	d.Attempts = updates["attempts"].(int)
+	d.LastStatusCode = updates["last_status_code"].(int)
+	d.LastError = updates["last_error"].(string)
+	if status, ok := updates["status"].(string); ok {
+		d.Status = status
+	}
+	switch next := updates["next_attempt_at"].(type) {
+	case time.Time:
+		d.NextAttemptAt = &next
+	case nil:
+		d.NextAttemptAt = nil
+	}
+	if at, ok := updates["delivered_at"].(time.Time); ok {
+		d.DeliveredAt = &at
+	}
+	return d
+}
+
+func TestDeliverSuccess(t *testing.T) {
+	sink := newWebhookSink(t, http.StatusNoContent)
+	d := model.WebhookDelivery{ID: 42, EventType: "inventory.changed", URL: sink.URL, Payload: `{"event":"inventory.changed"}`, Status: model.DeliveryPending}
+
+	d = apply(d, deliver(context.Background(), testWebhookSettings(), d))
+
+	if d.Status != model.DeliveryDelivered || d.Attempts != 1 || d.LastStatusCode != http.StatusNoContent {
+		t.Fatalf("got status=%s attempts=%d code=%d, want delivered after 1 attempt with 204", d.Status, d.Attempts, d.LastStatusCode)
+	}
+	if d.DeliveredAt == nil || d.NextAttemptAt != nil {
+		t.Fatalf("got delivered_at=%v next_attempt_at=%v, want delivered_at set and no next attempt", d.DeliveredAt, d.NextAttemptAt)
+	}
+
+	got := sink.received()
+	if len(got) != 1 {
+		t.Fatalf("sink got %d requests, want 1", len(got))
+	}
+	want := sinkRequest{event: "inventory.changed", delivery: "42", body: d.Payload}
+	if got[0] != want {
+		t.Fatalf("sink got %+v, want %+v", got[0], want)
+	}
+}
+
+func TestDeliverRetriesAfterServerError(t *testing.T) {
+	sink := newWebhookSink(t, http.StatusInternalServerError, http.StatusOK)
+	settings := testWebhookSettings()
+	d := model.WebhookDelivery{ID: 7, EventType: "sku.created", URL: sink.URL, Payload: `{}`, Status: model.DeliveryPending}
+
+	before := time.Now().UTC()
+	d = apply(d, deliver(context.Background(), settings, d))
+
+	if d.Status != model.DeliveryPending || d.Attempts != 1 || d.LastStatusCode != http.StatusInternalServerError || d.LastError == "" {
+		t.Fatalf("got status=%s attempts=%d code=%d error=%q, want pending after a failed 500", d.Status, d.Attempts, d.LastStatusCode, d.LastError)
+	}
+	if d.NextAttemptAt == nil || d.NextAttemptAt.Before(before.Add(settings.backoff)) || d.NextAttemptAt.After(time.Now().UTC().Add(settings.backoff)) {
+		t.Fatalf("got next_attempt_at=%v, want one backoff (%s) from now", d.NextAttemptAt, settings.backoff)
+	}
+
+	d = apply(d, deliver(context.Background(), settings, d))
+
+	if d.Status != model.DeliveryDelivered || d.Attempts != 2 || d.LastError != "" || d.NextAttemptAt != nil {
+		t.Fatalf("got status=%s attempts=%d error=%q next=%v, want delivered on the retry", d.Status, d.Attempts, d.LastError, d.NextAttemptAt)
+	}
+	if got := sink.received(); len(got) != 2 || got[0].delivery != got[1].delivery {
+		t.Fatalf("sink got %+v, want the same delivery id twice", got)
+	}
+}
+
+func TestDeliverFailsAfterMaxAttempts(t *testing.T) {
+	sink := newWebhookSink(t, http.StatusServiceUnavailable)
+	settings := testWebhookSettings()
+	d := model.WebhookDelivery{ID: 9, EventType: "sku.created", URL: sink.URL, Payload: `{}`, Status: model.DeliveryPending}
+
+	for i := 1; i < settings.maxAttempts; i++ {
+		d = apply(d, deliver(context.Background(), settings, d))
+		if d.Status != model.DeliveryPending || d.NextAttemptAt == nil {
+			t.Fatalf("attempt %d: got status=%s next=%v, want pending with a next attempt", i, d.Status, d.NextAttemptAt)
+		}
+	}
+	d = apply(d, deliver(context.Background(), settings, d))
+
+	if d.Status != model.DeliveryFailed || d.Attempts != settings.maxAttempts || d.NextAttemptAt != nil {
+		t.Fatalf("got status=%s attempts=%d next=%v, want failed after %d attempts", d.Status, d.Attempts, d.NextAttemptAt, settings.maxAttempts)
+	}
+	if d.LastStatusCode != http.StatusServiceUnavailable || d.LastError == "" {
+		t.Fatalf("got code=%d error=%q, want the last 503 recorded", d.LastStatusCode, d.LastError)
+	}
+}
+
+func TestRetryAt(t *testing.T) {
+	settings := webhookSettings{backoff: 30 * time.Second}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{5, 8 * time.Minute},
+		{20, maxWebhookBackoff},
+		{1000, maxWebhookBackoff},
+	}
+	for _, tt := range tests {
+		if got := settings.retryAt(now, tt.attempts).Sub(now); got != tt.want {
+			t.Errorf("retryAt after %d attempts = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
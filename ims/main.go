@@ -11,8 +11,10 @@ import (
 	"github.com/omniful/go_commons/http"
 	"github.com/omniful/go_commons/log"
 
+	"ims/middleware"
 	"ims/postgres"
 	"ims/router"
+	"ims/worker"
 )
 
 func main() {
@@ -29,9 +31,23 @@ func main() {
 	// Initialize Postgres and run migrations
 	pr.InitPostgres(ctx)
 
+	// Confine GORM statements to the caller's tenant
+	if err := middleware.RegisterTenantScope(pr.DB.GetMasterDB(ctx), pr.DB.GetSlaveDB(ctx)); err != nil {
+		log.Panicf("Failed to register tenant scope: %v", err)
+	}
+
 	// Initialize Redis (if Redis code is similar)
 	pr.InitRedis(ctx)
 
+	// Initialize Kafka producer for inventory events
+	pr.InitKafkaProducer(ctx)
+
+	// Return expired reservation holds to stock in the background
+	go worker.StartReservationSweeper(ctx)
+
+	// Retry failed webhook deliveries in the background
+	go worker.StartWebhookSweeper(ctx)
+
 	// Set log level from config
 	log.SetLevel(config.GetString(ctx, "log.level"))
 
@@ -48,6 +64,7 @@ func main() {
 		env.RequestID(),
 		env.Middleware(config.GetString(ctx, "env")),
 		config.Middleware(),
+		middleware.Authenticate(ctx, "/health"),
 	)
 
 	// Health check endpoint
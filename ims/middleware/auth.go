@@ -0,0 +1,164 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omniful/go_commons/config"
+	"github.com/omniful/go_commons/i18n"
+	"github.com/omniful/go_commons/log"
+)
+
+const (
+	// HeaderAPIKey carries a tenant API key or the service credential
+	HeaderAPIKey = "X-API-Key"
+	// HeaderTenantID names the tenant a service caller acts for
+	HeaderTenantID = "X-Tenant-ID"
+	// HeaderActor names who is making a change, for the inventory ledger
+	HeaderActor = "X-Actor"
+)
+
+// ActorSystem is the actor of changes made without a request, such as the
+// reservation sweeper
+const ActorSystem = "system"
+
+type tenantKey struct{}
+
+type actorKey struct{}
+
+// WithTenant returns a copy of ctx scoped to tenantID
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+// TenantFromContext returns the tenant a request is scoped to. ok is false
+// for unscoped callers: the service credential without X-Tenant-ID, and
+// background workers.
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenantID, ok := ctx.Value(tenantKey{}).(string)
+	return tenantID, ok && tenantID != ""
+}
+
+// WithActor returns a copy of ctx that records actor as the one making changes
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns who is making changes in ctx, or ActorSystem
+func ActorFromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return ActorSystem
+}
+
+// Authenticate resolves the caller from X-API-Key (or an "Authorization:
+// Bearer" token) and scopes the request context to its tenant.
+//
+// Tenant keys come from auth.api_keys as "<key>:<tenant_id>" entries. The
+// auth.service_key credential is for OMS and acts for the tenant named in
+// X-Tenant-ID, or for no tenant in particular when the header is absent.
+// The actor is X-Actor when given, otherwise "service" or "tenant:<id>".
+// A tenant_id in the query string or JSON body that differs from the
+// resolved tenant is rejected with 403.
+func Authenticate(ctx context.Context, skipPaths ...string) gin.HandlerFunc {
+	enabled := config.GetBool(ctx, "auth.enabled")
+	serviceKey := config.GetString(ctx, "auth.service_key")
+
+	tenants := make(map[string]string)
+	for _, entry := range config.GetStringSlice(ctx, "auth.api_keys") {
+		key, tenantID, ok := strings.Cut(entry, ":")
+		if !ok || key == "" || tenantID == "" {
+			log.DefaultLogger().Warnf("Ignoring malformed auth.api_keys entry")
+			continue
+		}
+		tenants[key] = tenantID
+	}
+
+	skip := make(map[string]bool, len(skipPaths))
+	for _, p := range skipPaths {
+		skip[p] = true
+	}
+
+	if !enabled {
+		log.DefaultLogger().Warnf("API authentication is disabled; every endpoint is open")
+	}
+
+	return func(c *gin.Context) {
+		if !enabled || skip[c.Request.URL.Path] {
+			if actor := c.GetHeader(HeaderActor); actor != "" {
+				c.Request = c.Request.WithContext(WithActor(c.Request.Context(), actor))
+			}
+			c.Next()
+			return
+		}
+
+		key := c.GetHeader(HeaderAPIKey)
+		if key == "" {
+			key = strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		}
+
+		var tenantID, actor string
+		switch {
+		case key == "":
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": i18n.Translate(c, "error.unauthorized")})
+			return
+		case serviceKey != "" && subtle.ConstantTimeCompare([]byte(key), []byte(serviceKey)) == 1:
+			tenantID, actor = c.GetHeader(HeaderTenantID), "service"
+		case tenants[key] != "":
+			tenantID = tenants[key]
+			actor = "tenant:" + tenantID
+		default:
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": i18n.Translate(c, "error.unauthorized")})
+			return
+		}
+
+		if tenantID != "" {
+			if !requestTenantMatches(c, tenantID) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": i18n.Translate(c, "error.tenant_forbidden")})
+				return
+			}
+			c.Request = c.Request.WithContext(WithTenant(c.Request.Context(), tenantID))
+		}
+		if named := c.GetHeader(HeaderActor); named != "" {
+			actor = named
+		}
+		c.Request = c.Request.WithContext(WithActor(c.Request.Context(), actor))
+
+		c.Next()
+	}
+}
+
+// requestTenantMatches reports whether the tenant_id a request names, in its
+// query string or top-level JSON body, is either absent or tenantID. The
+// body is put back for the handler.
+func requestTenantMatches(c *gin.Context, tenantID string) bool {
+	if t := c.Query("tenant_id"); t != "" && t != tenantID {
+		return false
+	}
+
+	if c.Request.Body == nil || !strings.HasPrefix(c.ContentType(), "application/json") {
+		return true
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	c.Request.Body.Close()
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return true
+	}
+
+	var named struct {
+		TenantID string `json:"tenant_id"`
+	}
+	// bodies that are not a JSON object are left to the handler
+	if json.Unmarshal(body, &named) != nil {
+		return true
+	}
+	return named.TenantID == "" || named.TenantID == tenantID
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequestTenantMatches(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name        string
+		query       string
+		contentType string
+		body        string
+		want        bool
+	}{
+		{"nothing named", "", "", "", true},
+		{"same tenant in query", "tenant_id=t1", "", "", true},
+		{"other tenant in query", "tenant_id=t2", "", "", false},
+		{"same tenant in body", "", "application/json", `{"tenant_id":"t1","sku_code":"A"}`, true},
+		{"other tenant in body", "", "application/json; charset=utf-8", `{"tenant_id":"t2"}`, false},
+		{"body without tenant", "", "application/json", `{"sku_code":"A"}`, true},
+		{"query checked before body", "tenant_id=t2", "application/json", `{"tenant_id":"t1"}`, false},
+		{"body that is not an object", "", "application/json", `[{"tenant_id":"t2"}]`, true},
+		{"nested tenant is not checked", "", "application/json", `{"lines":[{"tenant_id":"t2"}]}`, true},
+		{"non-JSON body is not read", "", "text/csv", "tenant_id\nt2\n", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, "/?"+tt.query, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				c.Request.Header.Set("Content-Type", tt.contentType)
+			}
+
+			if got := requestTenantMatches(c, "t1"); got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+
+			// the handler still gets the whole body
+			body, err := io.ReadAll(c.Request.Body)
+			if err != nil || string(body) != tt.body {
+				t.Fatalf("body after check = %q (%v), want %q", body, err, tt.body)
+			}
+		})
+	}
+}
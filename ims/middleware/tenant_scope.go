@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const tenantColumn = "tenant_id"
+
+// RegisterTenantScope installs GORM callbacks that confine every query,
+// update and delete on a model with a tenant_id column to the tenant in the
+// statement's context, and stamp that tenant on rows being created. Statements
+// without a tenant in their context (background workers, unscoped service
+// calls) are left as they are.
+func RegisterTenantScope(dbs ...*gorm.DB) error {
+	seen := make(map[*gorm.Config]bool)
+	for _, db := range dbs {
+		if db == nil || seen[db.Config] {
+			continue
+		}
+		seen[db.Config] = true
+
+		cb := db.Callback()
+		if err := cb.Query().Before("gorm:query").Register("tenant:scope_query", scopeToTenant); err != nil {
+			return err
+		}
+		if err := cb.Update().Before("gorm:update").Register("tenant:scope_update", scopeToTenant); err != nil {
+			return err
+		}
+		if err := cb.Delete().Before("gorm:delete").Register("tenant:scope_delete", scopeToTenant); err != nil {
+			return err
+		}
+		if err := cb.Create().Before("gorm:create").Register("tenant:stamp_create", stampTenant); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func scopeToTenant(db *gorm.DB) {
+	tenantID, ok := statementTenant(db)
+	if !ok {
+		return
+	}
+	db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: tenantColumn}, Value: tenantID},
+	}})
+}
+
+func stampTenant(db *gorm.DB) {
+	tenantID, ok := statementTenant(db)
+	if !ok {
+		return
+	}
+	db.Statement.SetColumn(tenantColumn, tenantID, true)
+}
+
+func statementTenant(db *gorm.DB) (string, bool) {
+	if db.Error != nil || db.Statement.Context == nil || db.Statement.Schema == nil {
+		return "", false
+	}
+	if db.Statement.Schema.LookUpField(tenantColumn) == nil {
+		return "", false
+	}
+	return TenantFromContext(db.Statement.Context)
+}
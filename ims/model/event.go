@@ -0,0 +1,73 @@
+package model
+
+import "time"
+
+// Reasons carried by InventoryUpdated
+const (
+	InventoryReasonCreated  = "created"
+	InventoryReasonUpdated  = "updated"
+	InventoryReasonReleased = "released"
+	InventoryReasonExpired  = "expired"
+	// stock returned by a cancelled order
+	InventoryReasonCancelRelease = "cancel_release"
+	// stock received from, dispatched on, or returned by an inter-hub transfer
+	InventoryReasonTransfer = "transfer"
+	// stock taken by /inventory/consume or /inventory/reserve
+	InventoryReasonConsumed = "consumed"
+	// stock held by a reservation
+	InventoryReasonReserved = "reserved"
+	// the inventory row was deleted
+	InventoryReasonDeleted = "deleted"
+)
+
+// InventoryUpdated describes a change in the stock available for a hub/SKU.
+// Kafka only carries increases; webhooks get every change.
+type InventoryUpdated struct {
+	TenantID   string    `json:"tenant_id"`
+	SellerID   string    `json:"seller_id"`
+	HubCode    string    `json:"hub_code"`
+	SKUCode    string    `json:"sku_code"`
+	Delta      int64     `json:"delta"` // change in available stock
+	Reason     string    `json:"reason"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// Webhook event types. A registration's event_type is one of these.
+const (
+	EventSKUCreated          = "sku.created"
+	EventSKUUpdated          = "sku.updated"
+	EventSKUDeleted          = "sku.deleted"
+	EventHubCreated          = "hub.created"
+	EventHubUpdated          = "hub.updated"
+	EventHubDeleted          = "hub.deleted"
+	EventInventoryUpdated    = "inventory.updated"
+	EventInventoryLowStock   = "inventory.low_stock"
+	EventInventoryOutOfStock = "inventory.out_of_stock"
+)
+
+// IsValidWebhookEvent reports whether IMS sends events of type s
+func IsValidWebhookEvent(s string) bool {
+	switch s {
+	case EventSKUCreated, EventSKUUpdated, EventSKUDeleted,
+		EventHubCreated, EventHubUpdated, EventHubDeleted,
+		EventInventoryUpdated, EventInventoryLowStock, EventInventoryOutOfStock:
+		return true
+	}
+	return false
+}
+
+// StockAlert is delivered to webhooks when a hub/SKU's available stock falls
+// to its reorder point or runs out
+type StockAlert struct {
+	TenantID        string    `json:"tenant_id"`
+	SellerID        string    `json:"seller_id"`
+	HubCode         string    `json:"hub_code"`
+	SKUCode         string    `json:"sku_code"`
+	Level           string    `json:"level"` // low_stock or out_of_stock
+	Quantity        int64     `json:"quantity"`
+	Reserved        int64     `json:"reserved"`
+	Available       int64     `json:"available"`
+	MinQuantity     int64     `json:"min_quantity"`
+	ReorderQuantity int64     `json:"reorder_quantity"`
+	OccurredAt      time.Time `json:"occurred_at"`
+}
@@ -1,17 +1,53 @@
 package model
 
-import "time"
+import (
+	"time"
 
+	"gorm.io/gorm"
+)
+
+// Inventory tracks stock for one SKU at one hub. Quantity is the on-hand
+// stock; Reserved is the part of it held by open reservations.
+// MinQuantity and ReorderQuantity drive the low-stock alerts.
 type Inventory struct {
-	ID        int64     `gorm:"primaryKey;autoIncrement" json:"id"`
-	TenantID  string    `gorm:"size:100;not null" json:"tenant_id"`
-	SellerID  string    `gorm:"size:100;not null" json:"seller_id"`
-	HubCode   string    `gorm:"size:100;not null" json:"hub_code"`
-	SKUCode   string    `gorm:"size:100;not null" json:"sku_code"`
-	Quantity  int64     `gorm:"default:0" json:"quantity"`
-	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+	ID              int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	TenantID        string    `gorm:"size:100;not null;uniqueIndex:idx_inventory_key" json:"tenant_id"`
+	SellerID        string    `gorm:"size:100;not null;uniqueIndex:idx_inventory_key" json:"seller_id"`
+	HubCode         string    `gorm:"size:100;not null;uniqueIndex:idx_inventory_key" json:"hub_code"`
+	SKUCode         string    `gorm:"size:100;not null;uniqueIndex:idx_inventory_key" json:"sku_code"`
+	Quantity        int64     `gorm:"default:0" json:"quantity"`
+	Reserved        int64     `gorm:"default:0" json:"reserved"`
+	Available       int64     `gorm:"-" json:"available"`
+	MinQuantity     int64     `gorm:"default:0" json:"min_quantity" binding:"gte=0"`     // reorder point: at or below it the row is low on stock
+	ReorderQuantity int64     `gorm:"default:0" json:"reorder_quantity" binding:"gte=0"` // how much to order when low, reported with alerts
+	UpdatedAt       time.Time `gorm:"autoUpdateTime" json:"updated_at"`
 }
 
 func (Inventory) TableName() string {
 	return "inventory"
 }
+
+// AfterFind fills in the stock that is free to reserve or consume
+func (i *Inventory) AfterFind(tx *gorm.DB) error {
+	i.Available = i.Quantity - i.Reserved
+	return nil
+}
+
+// Stock levels of an inventory row, from best to worst
+const (
+	StockLevelOK         = "ok"
+	StockLevelLow        = "low_stock"
+	StockLevelOutOfStock = "out_of_stock"
+)
+
+// StockLevel classifies the available stock: out of stock at zero, low at or
+// below MinQuantity
+func (i Inventory) StockLevel() string {
+	switch {
+	case i.Available <= 0:
+		return StockLevelOutOfStock
+	case i.Available <= i.MinQuantity:
+		return StockLevelLow
+	}
+	return StockLevelOK
+}
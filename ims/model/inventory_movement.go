@@ -0,0 +1,37 @@
+package model
+
+import "time"
+
+// Reasons an inventory quantity changes
+const (
+	MovementOrderConsume  = "order_consume"  // stock shipped against an order
+	MovementRestock       = "restock"        // stock received
+	MovementAdjustment    = "adjustment"     // manual correction or recount
+	MovementCancelRelease = "cancel_release" // stock returned by a cancelled order
+	MovementTransfer      = "transfer"       // stock moved between hubs
+)
+
+// ValidMovementReason reports whether reason is one of the movement reasons
+func ValidMovementReason(reason string) bool {
+	switch reason {
+	case MovementOrderConsume, MovementRestock, MovementAdjustment, MovementCancelRelease, MovementTransfer:
+		return true
+	}
+	return false
+}
+
+// InventoryMovement is one append-only entry of the inventory ledger: a change
+// of on-hand quantity for a hub/SKU and the quantity it left behind
+type InventoryMovement struct {
+	ID          int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	TenantID    string    `gorm:"size:100;not null;index:idx_inventory_movements_key" json:"tenant_id"`
+	SellerID    string    `gorm:"size:100;not null;index:idx_inventory_movements_key" json:"seller_id"`
+	HubCode     string    `gorm:"size:100;not null;index:idx_inventory_movements_key" json:"hub_code"`
+	SKUCode     string    `gorm:"size:100;not null;index:idx_inventory_movements_key" json:"sku_code"`
+	Delta       int64     `gorm:"not null" json:"delta"`
+	Balance     int64     `gorm:"not null" json:"balance"` // on-hand quantity after the change
+	Reason      string    `gorm:"size:30;not null" json:"reason"`
+	ReferenceID string    `gorm:"size:100;index" json:"reference_id,omitempty"` // e.g. the OMS order id
+	Actor       string    `gorm:"size:100;not null" json:"actor"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
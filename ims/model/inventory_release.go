@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// InventoryRelease records that the stock of a reference, e.g. a cancelled
+// OMS order, was returned through /inventory/release. There is at most one
+// per reference, so the same stock is never added back twice.
+type InventoryRelease struct {
+	TenantID    string    `gorm:"primaryKey;size:100" json:"tenant_id"`
+	SellerID    string    `gorm:"primaryKey;size:100" json:"seller_id"`
+	ReferenceID string    `gorm:"primaryKey;size:100" json:"reference_id"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (InventoryRelease) TableName() string {
+	return "inventory_releases"
+}
@@ -0,0 +1,25 @@
+package model
+
+import "testing"
+
+func TestStockLevel(t *testing.T) {
+	tests := []struct {
+		available, min int64
+		want           string
+	}{
+		{10, 3, StockLevelOK},
+		{4, 3, StockLevelOK},
+		{3, 3, StockLevelLow},
+		{1, 3, StockLevelLow},
+		{0, 3, StockLevelOutOfStock},
+		{-2, 3, StockLevelOutOfStock},
+		{1, 0, StockLevelOK},
+		{0, 0, StockLevelOutOfStock},
+	}
+	for _, tt := range tests {
+		i := Inventory{Available: tt.available, MinQuantity: tt.min}
+		if got := i.StockLevel(); got != tt.want {
+			t.Errorf("StockLevel() with available=%d min=%d = %s, want %s", tt.available, tt.min, got, tt.want)
+		}
+	}
+}
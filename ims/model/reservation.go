@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+// Reservation statuses
+const (
+	ReservationHeld      = "held"
+	ReservationCommitted = "committed"
+	ReservationReleased  = "released"
+	ReservationExpired   = "expired"
+)
+
+// Reservation is a time-limited hold on stock across one or more lines
+type Reservation struct {
+	ID          string            `gorm:"primaryKey;type:uuid;default:gen_random_uuid()" json:"reservation_id"`
+	TenantID    string            `gorm:"size:100;not null" json:"tenant_id"`
+	SellerID    string            `gorm:"size:100;not null" json:"seller_id"`
+	ReferenceID string            `gorm:"size:100" json:"reference_id"` // e.g. the OMS order id
+	Status      string            `gorm:"size:20;not null" json:"status"`
+	ExpiresAt   time.Time         `gorm:"not null" json:"expires_at"`
+	Lines       []ReservationLine `gorm:"foreignKey:ReservationID" json:"lines"`
+	CreatedAt   time.Time         `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time         `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// ReservationLine is the quantity held for one hub/SKU
+type ReservationLine struct {
+	ID            int64  `gorm:"primaryKey;autoIncrement" json:"-"`
+	ReservationID string `gorm:"type:uuid;not null" json:"-"`
+	HubCode       string `gorm:"size:100;not null" json:"hub_code"`
+	SKUCode       string `gorm:"size:100;not null" json:"sku_code"`
+	Quantity      int64  `gorm:"not null" json:"quantity"`
+}
@@ -0,0 +1,38 @@
+package model
+
+import "time"
+
+// Transfer statuses
+const (
+	TransferCreated    = "created"
+	TransferDispatched = "dispatched"
+	TransferReceived   = "received"
+	TransferCancelled  = "cancelled"
+)
+
+// Transfer moves stock of one or more SKUs from a source hub to a destination
+// hub of the same seller. Dispatching takes the stock out of the source; it
+// is in transit until the destination receives it.
+type Transfer struct {
+	ID             string         `gorm:"primaryKey;type:uuid;default:gen_random_uuid()" json:"transfer_id"`
+	TenantID       string         `gorm:"size:100;not null" json:"tenant_id"`
+	SellerID       string         `gorm:"size:100;not null" json:"seller_id"`
+	SourceHub      string         `gorm:"size:100;not null" json:"source_hub"`
+	DestinationHub string         `gorm:"size:100;not null" json:"destination_hub"`
+	ReferenceID    string         `gorm:"size:100" json:"reference_id,omitempty"`
+	Status         string         `gorm:"size:20;not null" json:"status"`
+	Lines          []TransferLine `gorm:"foreignKey:TransferID" json:"lines"`
+	DispatchedAt   *time.Time     `json:"dispatched_at,omitempty"`
+	ReceivedAt     *time.Time     `json:"received_at,omitempty"`
+	CancelledAt    *time.Time     `json:"cancelled_at,omitempty"`
+	CreatedAt      time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TransferLine is the quantity of one SKU being transferred
+type TransferLine struct {
+	ID         int64  `gorm:"primaryKey;autoIncrement" json:"-"`
+	TransferID string `gorm:"type:uuid;not null" json:"-"`
+	SKUCode    string `gorm:"size:100;not null" json:"sku_code"`
+	Quantity   int64  `gorm:"not null" json:"quantity"`
+}
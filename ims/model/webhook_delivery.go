@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+// Webhook delivery statuses
+const (
+	DeliveryPending   = "pending"   // waiting for its next attempt
+	DeliveryDelivered = "delivered" // the URL answered 2xx
+	DeliveryFailed    = "failed"    // gave up after the last attempt
+)
+
+// WebhookDelivery is one event sent to one webhook registration. The table is
+// the delivery log: each row keeps the body sent, the attempts made and the
+// outcome of the last one.
+type WebhookDelivery struct {
+	ID             int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	WebhookID      string     `gorm:"type:uuid;not null" json:"webhook_id"`
+	TenantID       string     `gorm:"not null" json:"tenant_id"`
+	EventType      string     `gorm:"not null" json:"event_type"`
+	URL            string     `gorm:"not null" json:"url"`
+	Payload        string     `gorm:"type:text;not null" json:"payload"` // the JSON body, as sent
+	Status         string     `gorm:"size:20;not null" json:"status"`
+	Attempts       int        `gorm:"not null;default:0" json:"attempts"`
+	LastStatusCode int        `json:"last_status_code,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,30 @@
+package pr
+
+import (
+	"context"
+
+	"github.com/omniful/go_commons/config"
+	"github.com/omniful/go_commons/kafka"
+	"github.com/omniful/go_commons/log"
+)
+
+var KafkaProducer *kafka.ProducerClient
+
+// InitKafkaProducer connects the producer used for IMS domain events.
+func InitKafkaProducer(ctx context.Context) {
+	logger := log.DefaultLogger()
+
+	brokers := config.GetStringSlice(ctx, "kafka.brokers")
+	version := config.GetString(ctx, "kafka.version")
+	if version == "" {
+		logger.Panicf("Kafka version is missing in config")
+	}
+
+	KafkaProducer = kafka.NewProducer(
+		kafka.WithBrokers(brokers),
+		kafka.WithClientID("ims-producer"),
+		kafka.WithKafkaVersion(version),
+	)
+
+	logger.Infof("Kafka producer initialized with brokers: %v, version: %s", brokers, version)
+}
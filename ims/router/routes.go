@@ -28,6 +28,7 @@ func RegisterRoutes(r *gin.Engine) {
 	r.DELETE("/hubs/:id", controllers.DeleteHub)
 	r.GET("/hubs", controllers.ListHubs)
 	r.GET("/hubs/code/:hub_code", controllers.GetHubByCode)
+	r.POST("/hubs/validate", controllers.ValidateHubs)
 
 	// --- SKUs ---
 	r.POST("/skus", controllers.CreateSKU)
@@ -36,14 +37,42 @@ func RegisterRoutes(r *gin.Engine) {
 	r.DELETE("/skus/:id", controllers.DeleteSKU)
 	r.GET("/skus", controllers.ListSKUs)
 	r.GET("/skus/code/:sku_code", controllers.GetSKUByCode)
+	r.POST("/skus/validate", controllers.ValidateSKUs)
 
 	r.POST("/inventory", controllers.CreateInventory)
 	r.GET("/inventory/:id", controllers.GetInventory)
 	r.PUT("/inventory/:id", controllers.UpdateInventory)
+	r.PUT("/inventory/upsert", controllers.UpsertInventory)
+	r.POST("/inventory/bulk", controllers.BulkUpsertInventory)
+	r.GET("/inventory/availability", controllers.GetInventoryAvailability)
+	r.GET("/inventory/movements", controllers.ListInventoryMovements)
+	r.GET("/inventory/snapshot", controllers.GetInventorySnapshot)
+	r.GET("/inventory/low-stock", controllers.GetLowStockInventory)
+	r.POST("/inventory/reconcile", controllers.ReconcileInventory)
 	r.DELETE("/inventory/:id", controllers.DeleteInventory)
 	r.GET("/inventory", controllers.ListInventory)
 	r.GET("/inventory/query", controllers.QueryInventory)      
 	r.POST("/inventory/consume", controllers.ConsumeInventory) 
+	r.POST("/inventory/reserve", controllers.ReserveInventory)
+	r.POST("/inventory/release", controllers.ReleaseInventory)
+
+	// --- Reservations ---
+	r.POST("/reservations", controllers.CreateReservation)
+	r.GET("/reservations/:id", controllers.GetReservation)
+	r.POST("/reservations/:id/commit", controllers.CommitReservation)
+	r.POST("/reservations/:id/release", controllers.ReleaseReservation)
+	r.POST("/reservations/:id/expire", controllers.ExpireReservation)
+
+	// --- Transfers ---
+	r.POST("/transfers", controllers.CreateTransfer)
+	r.GET("/transfers", controllers.ListTransfers)
+	r.GET("/transfers/:id", controllers.GetTransfer)
+	r.POST("/transfers/:id/dispatch", controllers.DispatchTransfer)
+	r.POST("/transfers/:id/receive", controllers.ReceiveTransfer)
+	r.POST("/transfers/:id/cancel", controllers.CancelTransfer)
+
+	// --- Cache ---
+	r.GET("/cache/stats", controllers.GetCacheStats)
 
 	// --- Webhooks ---
 	r.POST("/webhooks", controllers.CreateWebhook)
@@ -51,4 +80,6 @@ func RegisterRoutes(r *gin.Engine) {
 	r.PUT("/webhooks/:id", controllers.UpdateWebhook)
 	r.DELETE("/webhooks/:id", controllers.DeleteWebhook)
 	r.GET("/webhooks", controllers.ListWebhooks)
+	r.GET("/webhooks/:id/deliveries", controllers.ListWebhookDeliveries)
+	r.POST("/webhooks/:id/deliveries/:delivery_id/retry", controllers.RetryWebhookDelivery)
 }
@@ -0,0 +1,25 @@
+ALTER TABLE inventory ADD COLUMN IF NOT EXISTS reserved BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS reservations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id VARCHAR(100) NOT NULL,
+    seller_id VARCHAR(100) NOT NULL,
+    reference_id VARCHAR(100),
+    status VARCHAR(20) NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_reservations_status_expires_at ON reservations (status, expires_at);
+CREATE INDEX IF NOT EXISTS idx_reservations_reference_id ON reservations (reference_id);
+
+CREATE TABLE IF NOT EXISTS reservation_lines (
+    id BIGSERIAL PRIMARY KEY,
+    reservation_id UUID NOT NULL REFERENCES reservations (id) ON DELETE CASCADE,
+    hub_code VARCHAR(100) NOT NULL,
+    sku_code VARCHAR(100) NOT NULL,
+    quantity BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_reservation_lines_reservation_id ON reservation_lines (reservation_id);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_skus_scope_code;
+DROP INDEX IF EXISTS idx_hubs_scope_code;
+
+ALTER TABLE skus ADD CONSTRAINT skus_sku_code_key UNIQUE (sku_code);
+ALTER TABLE hubs ADD CONSTRAINT hubs_hub_code_key UNIQUE (hub_code);
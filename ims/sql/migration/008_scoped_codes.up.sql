@@ -0,0 +1,5 @@
+ALTER TABLE skus DROP CONSTRAINT IF EXISTS skus_sku_code_key;
+ALTER TABLE hubs DROP CONSTRAINT IF EXISTS hubs_hub_code_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_skus_scope_code ON skus (tenant_id, seller_id, sku_code);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_hubs_scope_code ON hubs (tenant_id, seller_id, hub_code);
@@ -0,0 +1,7 @@
+ALTER TABLE inventory DROP CONSTRAINT IF EXISTS fk_inventory_sku;
+ALTER TABLE inventory DROP CONSTRAINT IF EXISTS fk_inventory_hub;
+ALTER TABLE skus DROP CONSTRAINT IF EXISTS fk_skus_seller;
+ALTER TABLE hubs DROP CONSTRAINT IF EXISTS fk_hubs_seller;
+ALTER TABLE sellers DROP CONSTRAINT IF EXISTS fk_sellers_tenant;
+
+DROP INDEX IF EXISTS idx_sellers_tenant_seller;
@@ -0,0 +1,28 @@
+-- Tenants own sellers, sellers own hubs and SKUs, and inventory belongs to a
+-- hub and a SKU of the same seller. Deletes are restricted: dependents have
+-- to be removed first. Hub and SKU code renames carry over to inventory.
+-- The constraints are NOT VALID so existing orphans do not block the
+-- migration; they apply to every new or changed row. Once orphans are
+-- cleaned up, run VALIDATE CONSTRAINT on each of them.
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_sellers_tenant_seller ON sellers (tenant_id, seller_id);
+
+ALTER TABLE sellers
+    ADD CONSTRAINT fk_sellers_tenant FOREIGN KEY (tenant_id)
+    REFERENCES tenants (tenant_id) ON UPDATE RESTRICT ON DELETE RESTRICT NOT VALID;
+
+ALTER TABLE hubs
+    ADD CONSTRAINT fk_hubs_seller FOREIGN KEY (tenant_id, seller_id)
+    REFERENCES sellers (tenant_id, seller_id) ON UPDATE RESTRICT ON DELETE RESTRICT NOT VALID;
+
+ALTER TABLE skus
+    ADD CONSTRAINT fk_skus_seller FOREIGN KEY (tenant_id, seller_id)
+    REFERENCES sellers (tenant_id, seller_id) ON UPDATE RESTRICT ON DELETE RESTRICT NOT VALID;
+
+ALTER TABLE inventory
+    ADD CONSTRAINT fk_inventory_hub FOREIGN KEY (tenant_id, seller_id, hub_code)
+    REFERENCES hubs (tenant_id, seller_id, hub_code) ON UPDATE CASCADE ON DELETE RESTRICT NOT VALID;
+
+ALTER TABLE inventory
+    ADD CONSTRAINT fk_inventory_sku FOREIGN KEY (tenant_id, seller_id, sku_code)
+    REFERENCES skus (tenant_id, seller_id, sku_code) ON UPDATE CASCADE ON DELETE RESTRICT NOT VALID;
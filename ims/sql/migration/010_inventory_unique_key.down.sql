@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_inventory_key;
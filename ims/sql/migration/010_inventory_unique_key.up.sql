@@ -0,0 +1,26 @@
+-- Fold duplicate hub/SKU rows into the oldest one before the key becomes
+-- unique. Quantities and reservations are summed so no stock is lost.
+WITH ranked AS (
+    SELECT id, MIN(id) OVER (PARTITION BY tenant_id, seller_id, hub_code, sku_code) AS keep_id
+    FROM inventory
+), totals AS (
+    SELECT r.keep_id, SUM(COALESCE(i.quantity, 0)) AS quantity, SUM(i.reserved) AS reserved
+    FROM inventory i
+    JOIN ranked r ON r.id = i.id
+    GROUP BY r.keep_id
+    HAVING COUNT(*) > 1
+)
+UPDATE inventory
+SET quantity = totals.quantity, reserved = totals.reserved, updated_at = NOW()
+FROM totals
+WHERE inventory.id = totals.keep_id;
+
+DELETE FROM inventory dup
+USING inventory keep
+WHERE dup.tenant_id = keep.tenant_id
+  AND dup.seller_id = keep.seller_id
+  AND dup.hub_code = keep.hub_code
+  AND dup.sku_code = keep.sku_code
+  AND dup.id > keep.id;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_inventory_key ON inventory (tenant_id, seller_id, hub_code, sku_code);
@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS inventory_movements (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id VARCHAR(100) NOT NULL,
+    seller_id VARCHAR(100) NOT NULL,
+    hub_code VARCHAR(100) NOT NULL,
+    sku_code VARCHAR(100) NOT NULL,
+    delta BIGINT NOT NULL,
+    balance BIGINT NOT NULL,
+    reason VARCHAR(30) NOT NULL CHECK (reason IN ('order_consume', 'restock', 'adjustment', 'cancel_release', 'transfer')),
+    reference_id VARCHAR(100),
+    actor VARCHAR(100) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_inventory_movements_key ON inventory_movements (tenant_id, seller_id, hub_code, sku_code, id);
+CREATE INDEX IF NOT EXISTS idx_inventory_movements_reference_id ON inventory_movements (reference_id);
+
+-- The ledger is append-only: corrections are new movements, never edits
+CREATE OR REPLACE FUNCTION inventory_movements_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'inventory_movements is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_inventory_movements_append_only ON inventory_movements;
+CREATE TRIGGER trg_inventory_movements_append_only
+    BEFORE UPDATE OR DELETE ON inventory_movements
+    FOR EACH ROW EXECUTE FUNCTION inventory_movements_append_only();
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_inventory_movements_created_at;
+
+-- The opening balances stay: the ledger is append-only.
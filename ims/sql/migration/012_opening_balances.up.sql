@@ -0,0 +1,16 @@
+-- Stock that has not moved since the ledger was introduced has no history,
+-- so snapshots would not see it. Give each such row an opening balance,
+-- dated by its last update.
+INSERT INTO inventory_movements (tenant_id, seller_id, hub_code, sku_code, delta, balance, reason, reference_id, actor, created_at)
+SELECT i.tenant_id, i.seller_id, i.hub_code, i.sku_code, i.quantity, i.quantity, 'adjustment', 'opening_balance', 'system', COALESCE(i.updated_at, NOW())
+FROM inventory i
+WHERE COALESCE(i.quantity, 0) <> 0
+  AND NOT EXISTS (
+      SELECT 1 FROM inventory_movements m
+      WHERE m.tenant_id = i.tenant_id
+        AND m.seller_id = i.seller_id
+        AND m.hub_code = i.hub_code
+        AND m.sku_code = i.sku_code
+  );
+
+CREATE INDEX IF NOT EXISTS idx_inventory_movements_created_at ON inventory_movements (tenant_id, seller_id, created_at);
@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS transfers (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id VARCHAR(100) NOT NULL,
+    seller_id VARCHAR(100) NOT NULL,
+    source_hub VARCHAR(100) NOT NULL,
+    destination_hub VARCHAR(100) NOT NULL,
+    reference_id VARCHAR(100),
+    status VARCHAR(20) NOT NULL,
+    dispatched_at TIMESTAMPTZ,
+    received_at TIMESTAMPTZ,
+    cancelled_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    CHECK (source_hub <> destination_hub),
+    CONSTRAINT fk_transfers_source_hub FOREIGN KEY (tenant_id, seller_id, source_hub)
+        REFERENCES hubs (tenant_id, seller_id, hub_code) ON UPDATE CASCADE ON DELETE RESTRICT,
+    CONSTRAINT fk_transfers_destination_hub FOREIGN KEY (tenant_id, seller_id, destination_hub)
+        REFERENCES hubs (tenant_id, seller_id, hub_code) ON UPDATE CASCADE ON DELETE RESTRICT
+);
+
+CREATE INDEX IF NOT EXISTS idx_transfers_source ON transfers (tenant_id, seller_id, source_hub, status);
+CREATE INDEX IF NOT EXISTS idx_transfers_destination ON transfers (tenant_id, seller_id, destination_hub, status);
+
+CREATE TABLE IF NOT EXISTS transfer_lines (
+    id BIGSERIAL PRIMARY KEY,
+    transfer_id UUID NOT NULL REFERENCES transfers (id) ON DELETE CASCADE,
+    sku_code VARCHAR(100) NOT NULL,
+    quantity BIGINT NOT NULL CHECK (quantity > 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_transfer_lines_transfer_id ON transfer_lines (transfer_id);
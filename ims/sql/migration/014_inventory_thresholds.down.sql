@@ -0,0 +1,3 @@
+ALTER TABLE inventory
+    DROP COLUMN IF EXISTS reorder_quantity,
+    DROP COLUMN IF EXISTS min_quantity;
@@ -0,0 +1,3 @@
+ALTER TABLE inventory
+    ADD COLUMN IF NOT EXISTS min_quantity BIGINT NOT NULL DEFAULT 0 CHECK (min_quantity >= 0),
+    ADD COLUMN IF NOT EXISTS reorder_quantity BIGINT NOT NULL DEFAULT 0 CHECK (reorder_quantity >= 0);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_webhook_registrations_tenant_event;
+DROP TABLE IF EXISTS webhook_deliveries;
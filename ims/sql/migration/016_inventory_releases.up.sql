@@ -0,0 +1,8 @@
+-- one row per reference whose stock came back through /inventory/release
+CREATE TABLE IF NOT EXISTS inventory_releases (
+    tenant_id VARCHAR(100) NOT NULL,
+    seller_id VARCHAR(100) NOT NULL,
+    reference_id VARCHAR(100) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (tenant_id, seller_id, reference_id)
+);
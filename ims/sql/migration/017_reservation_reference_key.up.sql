@@ -0,0 +1,42 @@
+-- Release all but the oldest held reservation of each reference before the
+-- reference becomes unique among held reservations. Their stock goes back
+-- to availability.
+WITH dups AS (
+    SELECT id
+    FROM (
+        SELECT id, ROW_NUMBER() OVER (PARTITION BY tenant_id, seller_id, reference_id ORDER BY created_at, id) AS n
+        FROM reservations
+        WHERE status = 'held' AND reference_id <> ''
+    ) ranked
+    WHERE n > 1
+), returned AS (
+    SELECT r.tenant_id, r.seller_id, l.hub_code, l.sku_code, SUM(l.quantity) AS quantity
+    FROM reservations r
+    JOIN reservation_lines l ON l.reservation_id = r.id
+    WHERE r.id IN (SELECT id FROM dups)
+    GROUP BY r.tenant_id, r.seller_id, l.hub_code, l.sku_code
+)
+UPDATE inventory
+SET reserved = inventory.reserved - returned.quantity, updated_at = NOW()
+FROM returned
+WHERE inventory.tenant_id = returned.tenant_id
+  AND inventory.seller_id = returned.seller_id
+  AND inventory.hub_code = returned.hub_code
+  AND inventory.sku_code = returned.sku_code;
+
+UPDATE reservations
+SET status = 'released', updated_at = NOW()
+WHERE id IN (
+    SELECT id
+    FROM (
+        SELECT id, ROW_NUMBER() OVER (PARTITION BY tenant_id, seller_id, reference_id ORDER BY created_at, id) AS n
+        FROM reservations
+        WHERE status = 'held' AND reference_id <> ''
+    ) ranked
+    WHERE n > 1
+);
+
+-- one open hold per reference, e.g. per OMS order
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reservations_held_reference
+    ON reservations (tenant_id, seller_id, reference_id)
+    WHERE status = 'held' AND reference_id <> '';
@@ -0,0 +1,62 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/omniful/go_commons/config"
+	"github.com/omniful/go_commons/log"
+
+	"ims/controllers"
+)
+
+const (
+	defaultSweepInterval = 30 * time.Second
+	sweepBatchSize       = 100
+)
+
+// StartReservationSweeper periodically returns expired reservation holds to stock
+func StartReservationSweeper(ctx context.Context) {
+	interval := config.GetDuration(ctx, "reservations.sweep_interval")
+	if interval <= 0 {
+		interval = defaultSweepInterval
+	}
+
+	log.Infof("Reservation sweeper started, interval=%s", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Infof("Reservation sweeper stopped")
+			return
+		case <-ticker.C:
+			sweepReservations(ctx)
+		}
+	}
+}
+
+// sweepReservations expires due holds batch by batch. Holds that fail are
+// left out of the following batches and retried on the next tick.
+func sweepReservations(ctx context.Context) {
+	var failed []string
+	for {
+		n, batchFailed, err := controllers.ExpireDueReservations(ctx, sweepBatchSize, failed)
+		if err != nil {
+			log.DefaultLogger().Errorf("Reservation sweep failed: %v", err)
+			return
+		}
+		if n > 0 {
+			log.Infof("Reservation sweep expired %d holds", n)
+		}
+		failed = append(failed, batchFailed...)
+		if n+len(batchFailed) < sweepBatchSize {
+			break
+		}
+	}
+	if len(failed) > 0 {
+		log.DefaultLogger().Errorf("Reservation sweep could not expire %d holds: %v", len(failed), failed)
+	}
+}
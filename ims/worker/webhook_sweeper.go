@@ -0,0 +1,51 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/omniful/go_commons/config"
+	"github.com/omniful/go_commons/log"
+
+	"ims/controllers"
+)
+
+// StartWebhookSweeper periodically retries webhook deliveries whose next
+// attempt is due
+func StartWebhookSweeper(ctx context.Context) {
+	interval := config.GetDuration(ctx, "webhooks.sweep_interval")
+	if interval <= 0 {
+		interval = defaultSweepInterval
+	}
+
+	log.Infof("Webhook sweeper started, interval=%s", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Infof("Webhook sweeper stopped")
+			return
+		case <-ticker.C:
+			sweepWebhookDeliveries(ctx)
+		}
+	}
+}
+
+func sweepWebhookDeliveries(ctx context.Context) {
+	for {
+		n, err := controllers.RetryDueWebhookDeliveries(ctx, sweepBatchSize)
+		if err != nil {
+			log.DefaultLogger().Errorf("Webhook sweep failed: %v", err)
+			return
+		}
+		if n > 0 {
+			log.Infof("Webhook sweep retried %d deliveries", n)
+		}
+		if n < sweepBatchSize {
+			return
+		}
+	}
+}
@@ -1,13 +1,21 @@
 package api
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"path"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/dhruv/oms/client"
 	"github.com/dhruv/oms/model"
 	service "github.com/dhruv/oms/services"
 	"github.com/gin-gonic/gin"
+	"github.com/omniful/go_commons/config"
 	"github.com/omniful/go_commons/log"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 // Handlers wraps services
@@ -25,7 +33,9 @@ func NewHandlers(orderService *service.OrderService) *Handlers {
 // CreateBulkOrder handles POST /orders/csv
 func (h *Handlers) CreateBulkOrder(c *gin.Context) {
 	var req struct {
-		Path string `json:"path" binding:"required"`
+		Path     string `json:"path" binding:"required"`
+		TenantID string `json:"tenant_id"`
+		SellerID string `json:"seller_id"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -37,7 +47,8 @@ func (h *Handlers) CreateBulkOrder(c *gin.Context) {
 	}
 
 	ctx := c.Request.Context()
-	if err := h.OrderService.ProcessCSV(ctx, req.Path); err != nil {
+	job, err := h.OrderService.ProcessCSV(ctx, req.Path, req.TenantID, req.SellerID)
+	if err != nil {
 		log.Errorf(" Failed to process CSV: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to process CSV file",
@@ -45,11 +56,85 @@ func (h *Handlers) CreateBulkOrder(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "CSV file processed successfully (S3 path validated)",
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "CSV file queued for processing",
+		"job":     job,
 	})
 }
 
+// GetBulkJob handles GET /orders/bulk/:job_id
+func (h *Handlers) GetBulkJob(c *gin.Context) {
+	job, err := client.GetBulkJob(c.Request.Context(), c.Param("job_id"))
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bulk job not found"})
+		return
+	}
+	if err != nil {
+		log.Errorf(" Failed to get bulk job: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get bulk job"})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// DownloadBulkJobErrors handles GET /orders/bulk/:job_id/errors.
+// Streams the job's error report, or with ?presign=true returns a presigned
+// S3 URL for it instead.
+func (h *Handlers) DownloadBulkJobErrors(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	job, err := client.GetBulkJob(ctx, c.Param("job_id"))
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bulk job not found"})
+		return
+	}
+	if err != nil {
+		log.Errorf(" Failed to get bulk job: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get bulk job"})
+		return
+	}
+	if job.ErrorFileKey == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bulk job has no error report", "state": job.State})
+		return
+	}
+
+	if c.Query("presign") == "true" {
+		ttl := config.GetDuration(ctx, "s3.presign_ttl")
+		if ttl <= 0 {
+			ttl = 15 * time.Minute
+		}
+		req, err := s3.NewPresignClient(h.OrderService.S3.Client).PresignGetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(job.Bucket),
+			Key:    aws.String(job.ErrorFileKey),
+		}, s3.WithPresignExpires(ttl))
+		if err != nil {
+			log.Errorf(" Failed to presign %s: %v", job.ErrorFileKey, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to presign error report"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"url":        req.URL,
+			"expires_at": time.Now().UTC().Add(ttl),
+		})
+		return
+	}
+
+	out, err := h.OrderService.S3.Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(job.Bucket),
+		Key:    aws.String(job.ErrorFileKey),
+	})
+	if err != nil {
+		log.Errorf(" Failed to get error report %s: %v", job.ErrorFileKey, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to fetch error report"})
+		return
+	}
+	defer out.Body.Close()
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", path.Base(job.ErrorFileKey)))
+	c.DataFromReader(http.StatusOK, aws.ToInt64(out.ContentLength), "text/csv", out.Body, nil)
+}
+
 // RegisterWebhook handles POST /webhooks
 func (h *Handlers) RegisterWebhook(c *gin.Context) {
 	var req model.Webhook
@@ -0,0 +1,355 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/omniful/go_commons/config"
+	"github.com/omniful/go_commons/log"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/dhruv/oms/client"
+	"github.com/dhruv/oms/model"
+)
+
+// ListOrders handles GET /orders?tenant_id=&seller_id=&status=&from=&to=&sort=&limit=&cursor=
+func (h *Handlers) ListOrders(c *gin.Context) {
+	filter := client.OrderFilter{
+		TenantID: c.Query("tenant_id"),
+		SellerID: c.Query("seller_id"),
+		Status:   c.Query("status"),
+		Cursor:   c.Query("cursor"),
+	}
+
+	if v := c.Query("from"); v != "" {
+		t, err := parseDateParam(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date, expected RFC3339 or YYYY-MM-DD"})
+			return
+		}
+		filter.From = &t
+	}
+	if v := c.Query("to"); v != "" {
+		t, err := parseDateParam(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date, expected RFC3339 or YYYY-MM-DD"})
+			return
+		}
+		filter.To = &t
+	}
+
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		filter.Limit = limit
+	}
+
+	switch c.DefaultQuery("sort", "desc") {
+	case "asc":
+		filter.Asc = true
+	case "desc":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort, expected asc or desc"})
+		return
+	}
+
+	page, err := client.ListOrders(c.Request.Context(), filter)
+	if errors.Is(err, client.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+		return
+	}
+	if err != nil {
+		log.Errorf(" Failed to list orders: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list orders"})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// GetOrder handles GET /orders/:id
+func (h *Handlers) GetOrder(c *gin.Context) {
+	order, err := client.GetOrderByID(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if err != nil {
+		log.Errorf(" Failed to get order: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get order"})
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+// manualStatuses are the statuses a caller may set through POST /orders/:id/status.
+// new_order, partially_allocated and on_hold are driven by inventory.
+var manualStatuses = map[string]bool{
+	model.OrderStatusPacked:    true,
+	model.OrderStatusShipped:   true,
+	model.OrderStatusDelivered: true,
+	model.OrderStatusReturned:  true,
+}
+
+// UpdateOrderStatus handles POST /orders/:id/status
+func (h *Handlers) UpdateOrderStatus(c *gin.Context) {
+	var req struct {
+		Status string `json:"status" binding:"required"`
+		Actor  string `json:"actor"`
+		Reason string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload"})
+		return
+	}
+	if !manualStatuses[req.Status] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Status cannot be set manually: " + req.Status})
+		return
+	}
+	if req.Actor == "" {
+		req.Actor = "api"
+	}
+
+	ctx := c.Request.Context()
+	id := c.Param("id")
+	err := client.UpdateOrderStatus(ctx, client.UpdateOrderStatusRequest{
+		OrderID: id,
+		Status:  req.Status,
+		Actor:   req.Actor,
+		Reason:  req.Reason,
+	})
+	switch {
+	case errors.Is(err, client.ErrOrderNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	case errors.Is(err, client.ErrIllegalTransition):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		log.Errorf(" Failed to update order status: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update order status"})
+		return
+	}
+
+	order, err := client.GetOrderByID(ctx, id)
+	if err != nil {
+		log.Errorf(" Failed to reload order %s: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get order"})
+		return
+	}
+
+	// deliveries outlive the request
+	client.NotifyWebhooks(context.WithoutCancel(ctx), order.TenantID, "order.updated", order)
+	c.JSON(http.StatusOK, order)
+}
+
+// stockReleaseLease is how long a cancellation holds the claim on returning
+// an order's stock; a claim left by a failed call lapses after it
+const stockReleaseLease = time.Minute
+
+// CancelOrder handles POST /orders/:id/cancel.
+// Orders can be cancelled until they ship. Stock already held or consumed for
+// the order is returned to IMS; if that fails the order stays cancelled and
+// calling the endpoint again retries the release.
+func (h *Handlers) CancelOrder(c *gin.Context) {
+	var req struct {
+		Actor  string `json:"actor"`
+		Reason string `json:"reason"`
+	}
+	// the body is optional
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload"})
+			return
+		}
+	}
+	if req.Actor == "" {
+		req.Actor = "api"
+	}
+
+	ctx := c.Request.Context()
+	id := c.Param("id")
+
+	order, err := client.GetOrderByID(ctx, id)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if err != nil {
+		log.Errorf(" Failed to get order: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get order"})
+		return
+	}
+
+	previous := order.Status
+	transitioned := false
+	if order.Status == model.OrderStatusCancelled {
+		// a retry; the status it was cancelled from is the last history entry
+		if n := len(order.StatusHistory); n > 0 {
+			previous = order.StatusHistory[n-1].From
+		}
+	} else {
+		err = client.UpdateOrderStatus(ctx, client.UpdateOrderStatusRequest{
+			OrderID: id,
+			From:    order.Status,
+			Status:  model.OrderStatusCancelled,
+			Actor:   req.Actor,
+			Reason:  req.Reason,
+		})
+		switch {
+		case errors.Is(err, client.ErrOrderNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+			return
+		case errors.Is(err, client.ErrIllegalTransition):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		case err != nil:
+			log.Errorf(" Failed to cancel order: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel order"})
+			return
+		}
+		transitioned = true
+	}
+
+	// on_hold orders never had stock taken for them
+	holdsStock := previous == model.OrderStatusNewOrder || previous == model.OrderStatusPacked || previous == model.OrderStatusPartiallyAllocated
+	released := false
+	if holdsStock && order.StockReleased == nil {
+		// a concurrent cancellation that holds the claim returns the stock
+		now := time.Now().UTC()
+		claimed, err := client.ClaimStockRelease(ctx, id, now, now.Add(stockReleaseLease))
+		if err != nil {
+			log.Errorf(" Failed to claim stock release for order %s: %v", id, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Order cancelled but stock could not be returned to IMS, retry the cancellation"})
+			return
+		}
+		if claimed {
+			if err := releaseOrderStock(ctx, order); err != nil {
+				log.Errorf(" Failed to return stock for cancelled order %s: %v", id, err)
+				if dropErr := client.DropStockReleaseClaim(ctx, id); dropErr != nil {
+					log.Errorf(" Failed to drop stock release claim for order %s: %v", id, dropErr)
+				}
+				c.JSON(http.StatusBadGateway, gin.H{"error": "Order cancelled but stock could not be returned to IMS, retry the cancellation"})
+				return
+			}
+			// if this fails a retry releases again once the claim lapses, and
+			// IMS answers that the order's stock is already back
+			if err := client.MarkStockReleased(ctx, id, time.Now().UTC()); err != nil {
+				log.Errorf(" Failed to mark stock released for order %s: %v", id, err)
+			}
+			released = true
+		}
+	}
+
+	order, err = client.GetOrderByID(ctx, id)
+	if err != nil {
+		log.Errorf(" Failed to reload order %s: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get order"})
+		return
+	}
+
+	if transitioned || released {
+		client.PublishOrderCancelled(ctx, model.OrderCancelled{
+			OrderID:        order.ID,
+			OrderRef:       order.OrderRef,
+			TenantID:       order.TenantID,
+			SellerID:       order.SellerID,
+			HubCode:        order.HubID,
+			Lines:          order.Lines,
+			PreviousStatus: previous,
+			Reason:         req.Reason,
+			StockReleased:  order.StockReleased != nil,
+			CancelledAt:    time.Now().UTC(),
+		})
+		// deliveries outlive the request
+		client.NotifyWebhooks(context.WithoutCancel(ctx), order.TenantID, "order.cancelled", order)
+	}
+	c.JSON(http.StatusOK, order)
+}
+
+// releaseOrderStock gives an order's stock back to IMS. A hold that is still
+// open is released; once it has been committed the consumed quantities are
+// added back to on-hand. For a partly filled order only the pending fill can
+// still be held, so the fills committed before it are added back. A pending
+// fill whose hold expired already went back to stock and is left out.
+func releaseOrderStock(ctx context.Context, order *model.Order) error {
+	baseURL := config.GetString(ctx, "ims.base_url")
+	timeout := config.GetDuration(ctx, "ims.timeout")
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var allocated []model.AllocationLine
+	if order.Allocation != nil {
+		allocated = order.Allocation.Lines
+	}
+
+	if order.ReservationID != "" {
+		err := client.ReleaseReservation(ctxWithTimeout, baseURL, order.ReservationID)
+		switch {
+		case err == nil && order.PendingFill == nil:
+			return nil
+		case err == nil, errors.Is(err, client.ErrReservationExpired) && order.PendingFill != nil:
+			// the pending fill was never consumed
+			allocated = subtractFill(allocated, order.PendingFill.Lines)
+			if len(allocated) == 0 {
+				return nil
+			}
+		case errors.Is(err, client.ErrReservationExpired) && order.FillVersion == 0:
+			// the order's only hold lapsed before it was committed
+			return nil
+		case !errors.Is(err, client.ErrReservationClosed):
+			return err
+		}
+	}
+
+	// stock goes back to the hubs it was allocated from
+	var lines []client.ReserveLine
+	if order.Allocation != nil {
+		for _, line := range allocated {
+			lines = append(lines, client.ReserveLine{HubCode: line.HubCode, SKUCode: line.SKUID, Quantity: line.Quantity})
+		}
+	} else {
+		for _, line := range order.Lines {
+			lines = append(lines, client.ReserveLine{HubCode: order.HubID, SKUCode: line.SKUID, Quantity: line.Quantity})
+		}
+	}
+	err := client.ReleaseInventory(ctxWithTimeout, baseURL, order.TenantID, order.SellerID, order.ID, lines)
+	if errors.Is(err, client.ErrStockAlreadyReleased) {
+		// an earlier attempt returned it but did not get to record that
+		return nil
+	}
+	return err
+}
+
+// subtractFill is what remains of allocated lines once a released fill is taken out
+func subtractFill(allocated, fill []model.AllocationLine) []model.AllocationLine {
+	var rest []model.AllocationLine
+	for _, a := range allocated {
+		for _, f := range fill {
+			if f.HubCode == a.HubCode && f.SKUID == a.SKUID {
+				a.Quantity -= f.Quantity
+			}
+		}
+		if a.Quantity > 0 {
+			rest = append(rest, a)
+		}
+	}
+	return rest
+}
+
+// parseDateParam accepts either a full RFC3339 timestamp or a plain date
+func parseDateParam(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t.UTC(), nil
+	}
+	return time.Parse("2006-01-02", v)
+}
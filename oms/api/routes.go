@@ -8,6 +8,12 @@ import (
 func RegisterRoutes(r *gin.Engine, h *Handlers) {
 	r.POST("/orders/csv", h.CreateBulkOrder)
 	r.POST("/orders/upload-local", h.UploadLocalCSVs)
+	r.GET("/orders", h.ListOrders)
+	r.GET("/orders/bulk/:job_id", h.GetBulkJob)
+	r.GET("/orders/bulk/:job_id/errors", h.DownloadBulkJobErrors)
+	r.GET("/orders/:id", h.GetOrder)
+	r.POST("/orders/:id/status", h.UpdateOrderStatus)
+	r.POST("/orders/:id/cancel", h.CancelOrder)
 	r.POST("/webhooks", h.RegisterWebhook)
 
 }
@@ -36,6 +36,7 @@ func (h *Handlers) UploadLocalCSVs(c *gin.Context) {
 	bucket := h.OrderService.S3.Bucket
 	var uploaded []string
 	var failed []string
+	jobs := make(map[string]string)
 
 	// 4) Upload and validate each file
 	for _, filePath := range files {
@@ -66,18 +67,21 @@ func (h *Handlers) UploadLocalCSVs(c *gin.Context) {
 		log.Infof(" Uploaded to S3: %s", key)
 
 		// Validate using OrderService
-		if err := h.OrderService.ProcessCSV(c.Request.Context(), key); err != nil {
+		job, err := h.OrderService.ProcessCSV(c.Request.Context(), key, "", "")
+		if err != nil {
 			log.Warnf(" Validation failed for %s: %v", fileName, err)
 			failed = append(failed, fileName)
 			continue
 		}
 
 		uploaded = append(uploaded, fileName)
+		jobs[fileName] = job.ID
 	}
 
 	// 5) Return result
 	c.JSON(202, gin.H{
 		"uploaded": uploaded,
+		"jobs":     jobs,
 		"failed":   failed,
 	})
 }
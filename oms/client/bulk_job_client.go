@@ -0,0 +1,188 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/omniful/go_commons/config"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/dhruv/oms/model"
+)
+
+// GetBulkJobsCollection returns the bulk_jobs collection
+func GetBulkJobsCollection(ctx context.Context) (*mongo.Collection, error) {
+	client, err := GetMongoClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	dbName := config.GetString(ctx, "mongodb.database")
+	return client.Database(dbName).Collection("bulk_jobs"), nil
+}
+
+// CreateBulkJob records a queued upload of bucket/fileKey
+func CreateBulkJob(ctx context.Context, job *model.BulkJob) error {
+	coll, err := GetBulkJobsCollection(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	job.ID = uuid.NewString()
+	job.TenantID = scopedTenant(ctx, job.TenantID)
+	job.State = model.BulkJobQueued
+	job.CreatedAt = now
+	job.UpdatedAt = now
+
+	if _, err := coll.InsertOne(ctx, job); err != nil {
+		mongoLogger.Errorf(" Failed to create bulk job: %v", err)
+		return err
+	}
+	return nil
+}
+
+// GetBulkJob loads a bulk job; returns mongo.ErrNoDocuments if missing
+func GetBulkJob(ctx context.Context, id string) (*model.BulkJob, error) {
+	coll, err := GetBulkJobsCollection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var job model.BulkJob
+	if err := coll.FindOne(ctx, scopeFilter(ctx, bson.M{"_id": id})).Decode(&job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ErrCheckpointMoved is returned when another consumer already checkpointed
+// past the batch being reported
+var ErrCheckpointMoved = errors.New("bulk job checkpoint moved")
+
+// StartBulkJob marks a job as picked up by the worker. started_at keeps the
+// first pickup when a redelivered message resumes the job.
+func StartBulkJob(ctx context.Context, id string) error {
+	coll, err := GetBulkJobsCollection(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	update := bson.M{
+		"$set": bson.M{"state": model.BulkJobProcessing, "updated_at": now},
+		"$min": bson.M{"started_at": now},
+	}
+	result, err := coll.UpdateOne(ctx, scopeFilter(ctx, bson.M{"_id": id}), update)
+	if err != nil {
+		mongoLogger.Errorf(" Failed to update bulk job %s: %v", id, err)
+		return fmt.Errorf("update error: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+// CheckpointBulkJob records that rows (from, to] were processed, adds the
+// batch's counts and remembers its report parts. It only applies while the
+// checkpoint is still at from, so a batch is never counted twice.
+func CheckpointBulkJob(ctx context.Context, id string, from, to int, p model.BulkJobProgress, errorPart, duplicatePart string) error {
+	coll, err := GetBulkJobsCollection(ctx)
+	if err != nil {
+		return err
+	}
+
+	update := bson.M{
+		"$set": bson.M{
+			"checkpoint_row": to,
+			"total":          to,
+			"updated_at":     time.Now().UTC(),
+		},
+		"$inc": bson.M{
+			"valid":          p.Valid,
+			"invalid":        p.Invalid,
+			"persisted":      p.Persisted,
+			"duplicates":     p.Duplicates,
+			"orders_created": p.OrdersCreated,
+		},
+	}
+	parts := bson.M{}
+	if errorPart != "" {
+		parts["error_parts"] = errorPart
+	}
+	if duplicatePart != "" {
+		parts["duplicate_parts"] = duplicatePart
+	}
+	if len(parts) > 0 {
+		update["$push"] = parts
+	}
+
+	result, err := coll.UpdateOne(ctx, scopeFilter(ctx, bson.M{"_id": id, "checkpoint_row": from}), update)
+	if err != nil {
+		mongoLogger.Errorf(" Failed to checkpoint bulk job %s: %v", id, err)
+		return fmt.Errorf("update error: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrCheckpointMoved
+	}
+	return nil
+}
+
+// FinishBulkJob completes a job with its merged report keys
+func FinishBulkJob(ctx context.Context, id, errorFileKey, duplicatesFileKey string) error {
+	now := time.Now().UTC()
+	set := bson.M{
+		"state":       model.BulkJobCompleted,
+		"finished_at": now,
+		"updated_at":  now,
+	}
+	if errorFileKey != "" {
+		set["error_file_key"] = errorFileKey
+	}
+	if duplicatesFileKey != "" {
+		set["duplicates_file_key"] = duplicatesFileKey
+	}
+	return updateBulkJob(ctx, id, set, "error_parts", "duplicate_parts")
+}
+
+// FailBulkJob marks a job that could not be processed at all
+func FailBulkJob(ctx context.Context, id, reason string) error {
+	now := time.Now().UTC()
+	return updateBulkJob(ctx, id, bson.M{
+		"state":       model.BulkJobFailed,
+		"error":       reason,
+		"finished_at": now,
+		"updated_at":  now,
+	})
+}
+
+func updateBulkJob(ctx context.Context, id string, set bson.M, unset ...string) error {
+	coll, err := GetBulkJobsCollection(ctx)
+	if err != nil {
+		return err
+	}
+
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		fields := bson.M{}
+		for _, f := range unset {
+			fields[f] = ""
+		}
+		update["$unset"] = fields
+	}
+
+	result, err := coll.UpdateOne(ctx, scopeFilter(ctx, bson.M{"_id": id}), update)
+	if err != nil {
+		mongoLogger.Errorf(" Failed to update bulk job %s: %v", id, err)
+		return fmt.Errorf("update error: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
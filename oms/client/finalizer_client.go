@@ -4,12 +4,17 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/omniful/go_commons/log"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/dhruv/oms/model"
 )
 
 // IMSInventory defines the expected response structure from IMS
@@ -20,6 +25,9 @@ type IMSInventory struct {
 	HubCode  string `json:"hub_code"`
 	SKUCode  string `json:"sku_code"`
 	Quantity int64  `json:"quantity"`
+	Reserved int64  `json:"reserved"`
+	// stock free to reserve
+	Available int64 `json:"available"`
 }
 
 // FetchInventory calls IMS to get inventory info
@@ -50,7 +58,7 @@ func FetchInventory(ctx context.Context, baseURL, tenantID, sellerID, hubCode, s
 		return nil, fmt.Errorf("new request error: %w", err)
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := doIMS(req, tenantID)
 	if err != nil {
 		return nil, fmt.Errorf("HTTP error: %w", err)
 	}
@@ -70,6 +78,45 @@ func FetchInventory(ctx context.Context, baseURL, tenantID, sellerID, hubCode, s
 	return &inv, nil
 }
 
+// FetchAvailability returns the stock of skuCodes at every hub of the seller.
+// Hubs without a row for a SKU have none of it.
+func FetchAvailability(ctx context.Context, baseURL, tenantID, sellerID string, skuCodes []string) ([]IMSInventory, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid baseURL: %w", err)
+	}
+	u.Path = "/inventory/availability"
+
+	q := u.Query()
+	q.Set("tenant_id", tenantID)
+	q.Set("seller_id", sellerID)
+	for _, sku := range skuCodes {
+		q.Add("sku_code", sku)
+	}
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("new request error: %w", err)
+	}
+
+	resp, err := doIMS(req, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("IMS returned status %d", resp.StatusCode)
+	}
+
+	var rows []IMSInventory
+	if err := json.NewDecoder(resp.Body).Decode(&rows); err != nil {
+		return nil, fmt.Errorf("decode error: %w", err)
+	}
+	return rows, nil
+}
+
 // ConsumeInventory calls IMS to consume stock
 func ConsumeInventory(ctx context.Context, baseURL, tenantID, sellerID, hubCode, skuCode string, qty int64) error {
 	url := fmt.Sprintf("%s/inventory/consume", baseURL)
@@ -91,7 +138,7 @@ func ConsumeInventory(ctx context.Context, baseURL, tenantID, sellerID, hubCode,
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := doIMS(req, tenantID)
 	if err != nil {
 		return fmt.Errorf("HTTP error: %w", err)
 	}
@@ -104,13 +151,37 @@ func ConsumeInventory(ctx context.Context, baseURL, tenantID, sellerID, hubCode,
 	return nil
 }
 
-// UpdateOrderStatus calls OMS to update the order's status
+// Actors recorded in status_history for system-driven changes
+const (
+	ActorCSVProcessor = "csv-processor"
+	ActorFinalizer    = "order-finalizer"
+)
+
+var (
+	// ErrOrderNotFound is returned when the order does not exist
+	ErrOrderNotFound = errors.New("order not found")
+	// ErrIllegalTransition is returned when the order's current status may not move to the requested one
+	ErrIllegalTransition = errors.New("illegal status transition")
+)
+
+// UpdateOrderStatusRequest moves an order to Status. The update only applies
+// while the order is still in From (or, if From is empty, in any status that
+// may legally move to Status), so concurrent consumers cannot regress it.
 type UpdateOrderStatusRequest struct {
-	OrderID string `json:"order_id"`
-	Status  string `json:"status"`
+	OrderID       string `json:"order_id"`
+	From          string `json:"from,omitempty"`
+	Status        string `json:"status"`
+	Actor         string `json:"actor"`
+	Reason        string `json:"reason,omitempty"`
+	ReservationID string `json:"reservation_id,omitempty"`
+	// recorded on the order when set
+	Allocation *model.Allocation `json:"allocation,omitempty"`
 }
 
-func UpdateOrderStatus(ctx context.Context, _ string, req UpdateOrderStatusRequest) error {
+// UpdateOrderStatus applies a status transition and appends it to status_history.
+// Without a From, moving an order to the status it already has is a no-op;
+// with one, an order no longer in From is ErrIllegalTransition.
+func UpdateOrderStatus(ctx context.Context, req UpdateOrderStatusRequest) error {
 	logger := log.DefaultLogger()
 
 	coll, err := GetOrdersCollection(ctx)
@@ -118,20 +189,220 @@ func UpdateOrderStatus(ctx context.Context, _ string, req UpdateOrderStatusReque
 		return fmt.Errorf("get collection error: %w", err)
 	}
 
-	filter := bson.M{"_id": req.OrderID}
-	update := bson.M{"$set": bson.M{"status": req.Status}}
+	if !model.IsValidOrderStatus(req.Status) {
+		return fmt.Errorf("%w: unknown status %q", ErrIllegalTransition, req.Status)
+	}
+
+	allowedFrom := model.StatusesLeadingTo(req.Status)
+	if req.From != "" {
+		if !model.CanTransition(req.From, req.Status) {
+			return fmt.Errorf("%w: %s -> %s", ErrIllegalTransition, req.From, req.Status)
+		}
+		allowedFrom = []string{req.From}
+	}
+
+	change := model.StatusChange{
+		To:     req.Status,
+		At:     time.Now().UTC(),
+		Actor:  req.Actor,
+		Reason: req.Reason,
+	}
+
+	set := bson.M{"status": req.Status}
+	if req.ReservationID != "" {
+		set["reservation_id"] = req.ReservationID
+	}
+	if req.Allocation != nil {
+		set["allocation"] = req.Allocation
+	}
+
+	// Try each allowed source status so the history records the real "from"
+	for _, from := range allowedFrom {
+		change.From = from
+		filter := scopeFilter(ctx, bson.M{"_id": req.OrderID, "status": from})
+		update := bson.M{
+			"$set":  set,
+			"$push": bson.M{"status_history": change},
+		}
+
+		result, err := coll.UpdateOne(ctx, filter, update)
+		if err != nil {
+			logger.Errorf(" Failed to update order status: %v", err)
+			return fmt.Errorf("update error: %w", err)
+		}
+		if result.MatchedCount == 1 {
+			logger.Infof(" Updated order status: OrderID=%s %s -> %s actor=%s", req.OrderID, from, req.Status, req.Actor)
+			return nil
+		}
+	}
 
-	result, err := coll.UpdateOne(ctx, filter, update)
+	// Nothing matched: either the order is missing or it is in another status
+	order, err := GetOrderByID(ctx, req.OrderID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		logger.Warnf(" No order found with ID %s to update", req.OrderID)
+		return ErrOrderNotFound
+	}
 	if err != nil {
-		logger.Errorf(" Failed to update order status: %v", err)
-		return fmt.Errorf("update error: %w", err)
+		return fmt.Errorf("load order error: %w", err)
+	}
+	// without a From, another consumer getting there first is not an error;
+	// with one, the caller's view of the order is stale
+	if req.From == "" && order.Status == req.Status {
+		return nil
+	}
+
+	logger.Warnf(" Rejected status change for OrderID=%s: %s -> %s", req.OrderID, order.Status, req.Status)
+	return fmt.Errorf("%w: %s -> %s", ErrIllegalTransition, order.Status, req.Status)
+}
+
+// RecordFillRequest records a partial fill on an order: its lines with the
+// allocated and backordered units, the cumulative allocation and the fill
+// still to be committed. It only applies while the order is in From at
+// FillVersion, so two fills of the same backorder cannot both land.
+type RecordFillRequest struct {
+	OrderID     string
+	From        string
+	Status      string
+	FillVersion int64
+	Lines       []model.OrderLine
+	Allocation  *model.Allocation
+	PendingFill *model.PendingFill // nil clears it
+	Actor       string
+	Reason      string
+}
+
+// RecordFill applies a partial fill. It returns ErrIllegalTransition when the
+// order has moved on or another fill landed first.
+func RecordFill(ctx context.Context, req RecordFillRequest) error {
+	coll, err := GetOrdersCollection(ctx)
+	if err != nil {
+		return fmt.Errorf("get collection error: %w", err)
+	}
+
+	if req.From != req.Status && !model.CanTransition(req.From, req.Status) {
+		return fmt.Errorf("%w: %s -> %s", ErrIllegalTransition, req.From, req.Status)
+	}
+
+	filter := bson.M{"_id": req.OrderID, "status": req.From, "fill_version": req.FillVersion}
+	if req.FillVersion == 0 {
+		filter["fill_version"] = bson.M{"$exists": false}
+	}
+
+	set := bson.M{"status": req.Status, "lines": req.Lines, "allocation": req.Allocation}
+	update := bson.M{"$inc": bson.M{"fill_version": 1}}
+	if req.PendingFill != nil {
+		set["pending_fill"] = req.PendingFill
+		set["reservation_id"] = req.PendingFill.ReservationID
+	} else {
+		update["$unset"] = bson.M{"pending_fill": ""}
+	}
+	update["$set"] = set
+	if req.From != req.Status {
+		update["$push"] = bson.M{"status_history": model.StatusChange{
+			From:   req.From,
+			To:     req.Status,
+			At:     time.Now().UTC(),
+			Actor:  req.Actor,
+			Reason: req.Reason,
+		}}
 	}
 
+	result, err := coll.UpdateOne(ctx, scopeFilter(ctx, filter), update)
+	if err != nil {
+		return fmt.Errorf("update error: %w", err)
+	}
 	if result.MatchedCount == 0 {
-		logger.Warnf(" No order found with ID %s to update", req.OrderID)
-		return fmt.Errorf("no order found with ID %s", req.OrderID)
+		log.DefaultLogger().Warnf(" Rejected fill for OrderID=%s: no longer %s at fill version %d", req.OrderID, req.From, req.FillVersion)
+		return fmt.Errorf("%w: order %s moved on", ErrIllegalTransition, req.OrderID)
+	}
+	log.DefaultLogger().Infof(" Recorded fill: OrderID=%s %s -> %s actor=%s", req.OrderID, req.From, req.Status, req.Actor)
+	return nil
+}
+
+// ClearPendingFill drops the pending fill once its hold has been committed
+func ClearPendingFill(ctx context.Context, orderID, reservationID string) error {
+	coll, err := GetOrdersCollection(ctx)
+	if err != nil {
+		return fmt.Errorf("get collection error: %w", err)
+	}
+
+	filter := scopeFilter(ctx, bson.M{"_id": orderID, "pending_fill.reservation_id": reservationID})
+	if _, err := coll.UpdateOne(ctx, filter, bson.M{"$unset": bson.M{"pending_fill": ""}}); err != nil {
+		return fmt.Errorf("update error: %w", err)
+	}
+	return nil
+}
+
+// MarkOrderAnnounced records that order.created went out for an order
+func MarkOrderAnnounced(ctx context.Context, orderID string, at time.Time) error {
+	coll, err := GetOrdersCollection(ctx)
+	if err != nil {
+		return fmt.Errorf("get collection error: %w", err)
+	}
+
+	filter := scopeFilter(ctx, bson.M{"_id": orderID, "announced_at": bson.M{"$exists": false}})
+	if _, err := coll.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"announced_at": at}}); err != nil {
+		return fmt.Errorf("update error: %w", err)
+	}
+	return nil
+}
+
+// ClaimStockRelease takes the return of a cancelled order's stock to IMS
+// until the given time. Only one caller holds the claim at a time, and a
+// claim left behind by a caller that failed lapses on its own. It reports
+// false when the stock was already released or someone else holds the claim.
+func ClaimStockRelease(ctx context.Context, orderID string, now, until time.Time) (bool, error) {
+	coll, err := GetOrdersCollection(ctx)
+	if err != nil {
+		return false, fmt.Errorf("get collection error: %w", err)
 	}
 
-	logger.Infof(" Updated order status: OrderID=%s Status=%s", req.OrderID, req.Status)
+	filter := scopeFilter(ctx, bson.M{
+		"_id":               orderID,
+		"status":            model.OrderStatusCancelled,
+		"stock_released_at": bson.M{"$exists": false},
+		"$or": bson.A{
+			bson.M{"stock_release_claimed_until": bson.M{"$exists": false}},
+			bson.M{"stock_release_claimed_until": bson.M{"$lte": now}},
+		},
+	})
+	result, err := coll.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"stock_release_claimed_until": until}})
+	if err != nil {
+		return false, fmt.Errorf("update error: %w", err)
+	}
+	return result.MatchedCount == 1, nil
+}
+
+// DropStockReleaseClaim gives up a claim whose release failed, so the next
+// cancellation can retry straight away
+func DropStockReleaseClaim(ctx context.Context, orderID string) error {
+	coll, err := GetOrdersCollection(ctx)
+	if err != nil {
+		return fmt.Errorf("get collection error: %w", err)
+	}
+
+	filter := scopeFilter(ctx, bson.M{"_id": orderID, "stock_released_at": bson.M{"$exists": false}})
+	if _, err := coll.UpdateOne(ctx, filter, bson.M{"$unset": bson.M{"stock_release_claimed_until": ""}}); err != nil {
+		return fmt.Errorf("update error: %w", err)
+	}
+	return nil
+}
+
+// MarkStockReleased records that a cancelled order's stock went back to IMS,
+// so a retried cancellation does not return it twice.
+func MarkStockReleased(ctx context.Context, orderID string, at time.Time) error {
+	coll, err := GetOrdersCollection(ctx)
+	if err != nil {
+		return fmt.Errorf("get collection error: %w", err)
+	}
+
+	filter := scopeFilter(ctx, bson.M{"_id": orderID, "status": model.OrderStatusCancelled, "stock_released_at": bson.M{"$exists": false}})
+	update := bson.M{
+		"$set":   bson.M{"stock_released_at": at},
+		"$unset": bson.M{"stock_release_claimed_until": ""},
+	}
+	if _, err := coll.UpdateOne(ctx, filter, update); err != nil {
+		return fmt.Errorf("update error: %w", err)
+	}
 	return nil
 }
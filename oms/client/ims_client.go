@@ -2,9 +2,12 @@ package client
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 
+	"github.com/omniful/go_commons/config"
 	"github.com/omniful/go_commons/log"
 )
 
@@ -16,17 +19,40 @@ func NewIMSClient(baseURL string) *IMSClient {
 	return &IMSClient{BaseURL: baseURL}
 }
 
-// CheckSKU validates SKU by sku_code
-func (c *IMSClient) CheckSKU(ctx context.Context, skuCode string) bool {
-	url := fmt.Sprintf("%s/skus/code/%s", c.BaseURL, skuCode)
+// imsServiceKey is the service credential OMS presents to IMS
+var imsServiceKey string
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+// InitIMSAuth loads the service credential used for every call to IMS
+func InitIMSAuth(ctx context.Context) {
+	imsServiceKey = config.GetString(ctx, "ims.service_key")
+	if imsServiceKey == "" {
+		log.DefaultLogger().Warnf(" ims.service_key is not set, IMS calls are unauthenticated")
+	}
+}
+
+// doIMS sends req to IMS with the service credential, acting for tenantID.
+// An empty tenantID leaves the call unscoped.
+func doIMS(req *http.Request, tenantID string) (*http.Response, error) {
+	if imsServiceKey != "" {
+		req.Header.Set("X-API-Key", imsServiceKey)
+	}
+	if tenantID != "" {
+		req.Header.Set("X-Tenant-ID", tenantID)
+	}
+	// recorded as the actor of stock changes in the IMS inventory ledger
+	req.Header.Set("X-Actor", "oms")
+	return http.DefaultClient.Do(req)
+}
+
+// CheckSKU validates SKU by sku_code within the tenant and seller
+func (c *IMSClient) CheckSKU(ctx context.Context, tenantID, sellerID, skuCode string) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.codeLookupURL("skus", tenantID, sellerID, skuCode), nil)
 	if err != nil {
 		log.DefaultLogger().Errorf(" CheckSKU request error: %v", err)
 		return false
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := doIMS(req, tenantID)
 	if err != nil {
 		log.DefaultLogger().Errorf(" CheckSKU HTTP error: %v", err)
 		return false
@@ -36,17 +62,15 @@ func (c *IMSClient) CheckSKU(ctx context.Context, skuCode string) bool {
 	return resp.StatusCode == http.StatusOK
 }
 
-// CheckHub validates Hub by hub_code
-func (c *IMSClient) CheckHub(ctx context.Context, hubCode string) bool {
-	url := fmt.Sprintf("%s/hubs/code/%s", c.BaseURL, hubCode)
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+// CheckHub validates Hub by hub_code within the tenant and seller
+func (c *IMSClient) CheckHub(ctx context.Context, tenantID, sellerID, hubCode string) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.codeLookupURL("hubs", tenantID, sellerID, hubCode), nil)
 	if err != nil {
 		log.DefaultLogger().Errorf(" CheckHub request error: %v", err)
 		return false
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := doIMS(req, tenantID)
 	if err != nil {
 		log.DefaultLogger().Errorf(" CheckHub HTTP error: %v", err)
 		return false
@@ -55,3 +79,67 @@ func (c *IMSClient) CheckHub(ctx context.Context, hubCode string) bool {
 
 	return resp.StatusCode == http.StatusOK
 }
+
+// codeLookupURL builds GET /<resource>/code/:code scoped to the tenant and seller
+func (c *IMSClient) codeLookupURL(resource, tenantID, sellerID, code string) string {
+	q := url.Values{}
+	q.Set("tenant_id", tenantID)
+	q.Set("seller_id", sellerID)
+	return fmt.Sprintf("%s/%s/code/%s?%s", c.BaseURL, resource, url.PathEscape(code), q.Encode())
+}
+
+// maxValidateCodes is the most codes IMS accepts in one validate call
+const maxValidateCodes = 500
+
+// ValidateSKUs asks IMS which of codes exist for the tenant and seller. Codes
+// are sent in chunks of maxValidateCodes; the result holds the existing ones.
+func (c *IMSClient) ValidateSKUs(ctx context.Context, tenantID, sellerID string, codes []string) (map[string]bool, error) {
+	return c.validateCodes(ctx, "skus", tenantID, sellerID, codes)
+}
+
+// ValidateHubs asks IMS which of codes exist for the tenant and seller
+func (c *IMSClient) ValidateHubs(ctx context.Context, tenantID, sellerID string, codes []string) (map[string]bool, error) {
+	return c.validateCodes(ctx, "hubs", tenantID, sellerID, codes)
+}
+
+func (c *IMSClient) validateCodes(ctx context.Context, resource, tenantID, sellerID string, codes []string) (map[string]bool, error) {
+	url := fmt.Sprintf("%s/%s/validate", c.BaseURL, resource)
+	valid := make(map[string]bool, len(codes))
+
+	for start := 0; start < len(codes); start += maxValidateCodes {
+		end := start + maxValidateCodes
+		if end > len(codes) {
+			end = len(codes)
+		}
+
+		resp, err := postIMS(ctx, url, tenantID, map[string]interface{}{
+			"tenant_id": tenantID,
+			"seller_id": sellerID,
+			"codes":     codes[start:end],
+		})
+		if err != nil {
+			log.DefaultLogger().Errorf(" Validate %s error: %v", resource, err)
+			return nil, err
+		}
+
+		var out struct {
+			Valid []string `json:"valid"`
+		}
+		err = func() error {
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				return fmt.Errorf("IMS returned status %d", resp.StatusCode)
+			}
+			return json.NewDecoder(resp.Body).Decode(&out)
+		}()
+		if err != nil {
+			log.DefaultLogger().Errorf(" Validate %s error: %v", resource, err)
+			return nil, err
+		}
+
+		for _, code := range out.Valid {
+			valid[code] = true
+		}
+	}
+	return valid, nil
+}
@@ -33,23 +33,15 @@ func InitKafkaProducer(ctx context.Context) {
 	kafkaLogger.Infof(" Kafka producer initialized with brokers: %v, version: %s", brokers, version)
 }
 
-func PublishOrderCreated(ctx context.Context, o *model.Order) {
-	event := model.OrderCreated{
-		OrderID:   o.ID,
-		TenantID:  o.TenantID,
-		SellerID:  o.SellerID,
-		HubCode:     o.HubID,
-		SKUCode:     o.SKUID,
-		Quantity:  o.Quantity,
-		CreatedAt: o.CreatedAt,
-	}
+func PublishOrderCreated(ctx context.Context, o *model.Order) error {
+	event := model.NewOrderCreated(o)
 	kafkaLogger.Infof(" Producer topic: %s", config.GetString(ctx, "kafka.producer_topic"))
 
 	payload, err := pubsub.NewEventInBytes(event)
 
 	if err != nil {
 		kafkaLogger.Errorf(" Failed to marshal OrderCreated: %v", err)
-		return
+		return err
 	}
 
 	msg := &pubsub.Message{
@@ -60,9 +52,33 @@ func PublishOrderCreated(ctx context.Context, o *model.Order) {
 	kafkaLogger.Infof(" About to publish to Kafka: topic=%s, key=%s, payload=%s", 
 	msg.Topic, msg.Key, string(msg.Value))
 
+	if err := producer.Publish(ctx, msg); err != nil {
+		kafkaLogger.Errorf(" Kafka publish error: %v", err)
+		return err
+	}
+	kafkaLogger.Infof(" Published order.created for OrderID: %s", o.ID)
+	return nil
+}
+
+// PublishOrderCancelled publishes order.cancelled for a cancelled order
+func PublishOrderCancelled(ctx context.Context, event model.OrderCancelled) {
+	topic := config.GetString(ctx, "kafka.cancelled_topic")
+
+	payload, err := pubsub.NewEventInBytes(event)
+	if err != nil {
+		kafkaLogger.Errorf(" Failed to marshal OrderCancelled: %v", err)
+		return
+	}
+
+	msg := &pubsub.Message{
+		Topic: topic,
+		Key:   event.OrderID,
+		Value: payload,
+	}
+
 	if err := producer.Publish(ctx, msg); err != nil {
 		kafkaLogger.Errorf(" Kafka publish error: %v", err)
 	} else {
-		kafkaLogger.Infof(" Published order.created for OrderID: %s", o.ID)
+		kafkaLogger.Infof(" Published %s for OrderID: %s", topic, event.OrderID)
 	}
 }
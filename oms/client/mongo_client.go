@@ -2,6 +2,7 @@ package client
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
@@ -11,28 +12,76 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 
+	"github.com/dhruv/oms/middleware"
 	"github.com/dhruv/oms/model"
 )
 
 var mongoLogger = log.DefaultLogger()
 
-// GetMongoClient returns a Mongo client with config values
-func GetMongoClient(ctx context.Context) (*mongo.Client, error) {
+// ErrDuplicateOrder is returned when an order with the same idempotency key already exists
+var ErrDuplicateOrder = errors.New("duplicate order")
+
+// scopeFilter confines filter to the tenant of the request in ctx, if any, so
+// a tenant caller can only read or change its own documents
+func scopeFilter(ctx context.Context, filter bson.M) bson.M {
+	if tenantID, ok := middleware.TenantFromContext(ctx); ok {
+		filter["tenant_id"] = tenantID
+	}
+	return filter
+}
+
+// scopedTenant returns the tenant of the request in ctx, or tenantID for
+// unscoped callers such as the workers
+func scopedTenant(ctx context.Context, tenantID string) string {
+	if scoped, ok := middleware.TenantFromContext(ctx); ok {
+		return scoped
+	}
+	return tenantID
+}
+
+// mongoClient is connected once by InitMongo and shared by every collection
+// helper; the driver pools connections behind it
+var mongoClient *mongo.Client
+
+// errMongoNotInitialized is returned by GetMongoClient before InitMongo ran
+var errMongoNotInitialized = errors.New("mongo client is not initialized")
+
+// InitMongo connects the Mongo client with config values
+func InitMongo(ctx context.Context) error {
 	uri := config.GetString(ctx, "mongodb.uri")
 	opts := options.Client().ApplyURI(uri)
 
 	client, err := mongo.Connect(ctx, opts)
 	if err != nil {
 		mongoLogger.Errorf(" Mongo Connect error: %v", err)
-		return nil, err
+		return err
 	}
 
 	if err := client.Ping(ctx, nil); err != nil {
 		mongoLogger.Errorf(" Mongo Ping error: %v", err)
-		return nil, err
+		return err
+	}
+
+	mongoClient = client
+	return nil
+}
+
+// CloseMongo disconnects the shared client on shutdown
+func CloseMongo(ctx context.Context) {
+	if mongoClient == nil {
+		return
+	}
+	if err := mongoClient.Disconnect(ctx); err != nil {
+		mongoLogger.Errorf(" Mongo Disconnect error: %v", err)
 	}
+}
 
-	return client, nil
+// GetMongoClient returns the client connected by InitMongo
+func GetMongoClient(ctx context.Context) (*mongo.Client, error) {
+	if mongoClient == nil {
+		return nil, errMongoNotInitialized
+	}
+	return mongoClient, nil
 }
 
 // GetOrdersCollection returns the orders collection
@@ -46,7 +95,8 @@ func GetOrdersCollection(ctx context.Context) (*mongo.Collection, error) {
 	return client.Database(dbName).Collection("orders"), nil
 }
 
-// SaveOrder inserts an order into MongoDB
+// SaveOrder inserts an order into MongoDB. Orders carrying an idempotency key
+// that was already used are rejected with ErrDuplicateOrder.
 func SaveOrder(ctx context.Context, o *model.Order) error {
 	coll, err := GetOrdersCollection(ctx)
 	if err != nil {
@@ -55,10 +105,21 @@ func SaveOrder(ctx context.Context, o *model.Order) error {
 	}
 
 	o.ID = uuid.NewString()
-	o.Status = "on_hold"
+	o.TenantID = scopedTenant(ctx, o.TenantID)
+	o.Status = model.OrderStatusOnHold
 	o.CreatedAt = time.Now().UTC()
+	o.StatusHistory = []model.StatusChange{{
+		To:     model.OrderStatusOnHold,
+		At:     o.CreatedAt,
+		Actor:  ActorCSVProcessor,
+		Reason: "order created",
+	}}
 
 	_, err = coll.InsertOne(ctx, o)
+	if mongo.IsDuplicateKeyError(err) {
+		mongoLogger.Warnf(" Order with idempotency key %s already exists", o.IdempotencyKey)
+		return ErrDuplicateOrder
+	}
 	if err != nil {
 		mongoLogger.Errorf(" Mongo InsertOne error: %v", err)
 		return err
@@ -83,6 +144,7 @@ func SaveWebhook(ctx context.Context, wh *model.Webhook) error {
 		return err
 	}
 	wh.ID = uuid.NewString()
+	wh.TenantID = scopedTenant(ctx, wh.TenantID)
 	wh.CreatedAt = time.Now().UTC()
 	wh.UpdatedAt = time.Now().UTC()
 	wh.IsActive = true // default to active on save
@@ -101,7 +163,7 @@ func GetWebhooksForTenantAndEvent(ctx context.Context, tenantID, event string) (
 			"$in": []string{event},
 		},
 	}
-	cursor, err := coll.Find(ctx, filter)
+	cursor, err := coll.Find(ctx, scopeFilter(ctx, filter))
 	if err != nil {
 		return nil, err
 	}
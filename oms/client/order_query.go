@@ -0,0 +1,235 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/dhruv/oms/model"
+)
+
+// ErrInvalidCursor is returned when a pagination cursor cannot be decoded
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+const (
+	DefaultOrderPageSize = 20
+	MaxOrderPageSize     = 100
+)
+
+// OrderFilter holds the optional filters for ListOrders
+type OrderFilter struct {
+	TenantID string
+	SellerID string
+	Status   string
+	From     *time.Time // inclusive lower bound on created_at
+	To       *time.Time // exclusive upper bound on created_at
+	Cursor   string     // next_cursor from the previous page
+	Limit    int64
+	Asc      bool // oldest first when true, newest first otherwise
+}
+
+// OrderPage is one page of ListOrders results
+type OrderPage struct {
+	Orders     []model.Order `json:"orders"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+// EnsureOrderIndexes creates the indexes backing the order list filters and
+// the idempotency key
+func EnsureOrderIndexes(ctx context.Context) error {
+	coll, err := GetOrdersCollection(ctx)
+	if err != nil {
+		return err
+	}
+
+	models := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "seller_id", Value: 1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "status", Value: 1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}},
+		// on_hold and backorder retry lookup when IMS reports new stock
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "hub_id", Value: 1}, {Key: "lines.sku_id", Value: 1}, {Key: "created_at", Value: 1}}},
+		// replayed CSV rows must not create a second order
+		{
+			Keys: bson.D{{Key: "idempotency_key", Value: 1}},
+			Options: options.Index().
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"idempotency_key": bson.M{"$type": "string"}}),
+		},
+	}
+
+	names, err := coll.Indexes().CreateMany(ctx, models)
+	if err != nil {
+		mongoLogger.Errorf(" Failed to create order indexes: %v", err)
+		return err
+	}
+
+	mongoLogger.Infof(" Order indexes ensured: %v", names)
+	return nil
+}
+
+// GetOrderByID loads a single order; returns mongo.ErrNoDocuments if missing
+func GetOrderByID(ctx context.Context, id string) (*model.Order, error) {
+	coll, err := GetOrdersCollection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var order model.Order
+	if err := coll.FindOne(ctx, scopeFilter(ctx, bson.M{"_id": id})).Decode(&order); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// GetOrderByIdempotencyKey loads the order created for key; returns
+// mongo.ErrNoDocuments if there is none
+func GetOrderByIdempotencyKey(ctx context.Context, key string) (*model.Order, error) {
+	coll, err := GetOrdersCollection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var order model.Order
+	if err := coll.FindOne(ctx, scopeFilter(ctx, bson.M{"idempotency_key": key})).Decode(&order); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// FindAwaitingStockOrders returns on_hold and partially_allocated orders that
+// contain skuID at hubID, or at any hub when hubID is empty, oldest first
+func FindAwaitingStockOrders(ctx context.Context, tenantID, sellerID, hubID, skuID string, limit int64) ([]model.Order, error) {
+	coll, err := GetOrdersCollection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := bson.M{
+		"status":       bson.M{"$in": []string{model.OrderStatusOnHold, model.OrderStatusPartiallyAllocated}},
+		"tenant_id":    tenantID,
+		"seller_id":    sellerID,
+		"lines.sku_id": skuID,
+	}
+	if hubID != "" {
+		filter["hub_id"] = hubID
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}).
+		SetLimit(limit)
+
+	cur, err := coll.Find(ctx, scopeFilter(ctx, filter), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var orders []model.Order
+	if err := cur.All(ctx, &orders); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// ListOrders returns a page of orders sorted by created_at using keyset pagination
+func ListOrders(ctx context.Context, f OrderFilter) (*OrderPage, error) {
+	coll, err := GetOrdersCollection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.Limit <= 0 {
+		f.Limit = DefaultOrderPageSize
+	}
+	if f.Limit > MaxOrderPageSize {
+		f.Limit = MaxOrderPageSize
+	}
+
+	filter := bson.M{}
+	if f.TenantID != "" {
+		filter["tenant_id"] = f.TenantID
+	}
+	if f.SellerID != "" {
+		filter["seller_id"] = f.SellerID
+	}
+	if f.Status != "" {
+		filter["status"] = f.Status
+	}
+
+	createdAt := bson.M{}
+	if f.From != nil {
+		createdAt["$gte"] = *f.From
+	}
+	if f.To != nil {
+		createdAt["$lt"] = *f.To
+	}
+	if len(createdAt) > 0 {
+		filter["created_at"] = createdAt
+	}
+
+	op, dir := "$lt", -1
+	if f.Asc {
+		op, dir = "$gt", 1
+	}
+
+	if f.Cursor != "" {
+		cursorTime, cursorID, err := decodeOrderCursor(f.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		// (created_at, _id) strictly after the last order of the previous page
+		filter["$or"] = bson.A{
+			bson.M{"created_at": bson.M{op: cursorTime}},
+			bson.M{"created_at": cursorTime, "_id": bson.M{op: cursorID}},
+		}
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: dir}, {Key: "_id", Value: dir}}).
+		SetLimit(f.Limit + 1)
+
+	cur, err := coll.Find(ctx, scopeFilter(ctx, filter), opts)
+	if err != nil {
+		mongoLogger.Errorf(" Mongo Find orders error: %v", err)
+		return nil, err
+	}
+
+	orders := []model.Order{}
+	if err := cur.All(ctx, &orders); err != nil {
+		return nil, err
+	}
+
+	page := &OrderPage{Orders: orders}
+	if int64(len(orders)) > f.Limit {
+		page.Orders = orders[:f.Limit]
+		last := page.Orders[len(page.Orders)-1]
+		page.NextCursor = encodeOrderCursor(last.CreatedAt, last.ID)
+	}
+	return page, nil
+}
+
+func encodeOrderCursor(createdAt time.Time, id string) string {
+	raw := createdAt.UTC().Format(time.RFC3339Nano) + "|" + id
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeOrderCursor(cursor string) (time.Time, string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+	parts := strings.SplitN(string(raw), "|", 2)
+	if len(parts) != 2 {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+	t, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+	return t, parts[1], nil
+}
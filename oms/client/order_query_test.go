@@ -0,0 +1,53 @@
+package client
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestOrderCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name      string
+		createdAt time.Time
+		id        string
+	}{
+		{"object id", time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC), "663208a1f1c2a4b5c6d7e8f9"},
+		{"nanoseconds", time.Date(2024, 5, 1, 10, 30, 0, 123456789, time.UTC), "a"},
+		{"other zone", time.Date(2024, 5, 1, 16, 0, 0, 0, time.FixedZone("IST", 5*3600+1800)), "b"},
+		{"id with separator", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), "x|y"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at, id, err := decodeOrderCursor(encodeOrderCursor(tt.createdAt, tt.id))
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if !at.Equal(tt.createdAt) || id != tt.id {
+				t.Fatalf("got (%s, %q), want (%s, %q)", at, id, tt.createdAt, tt.id)
+			}
+		})
+	}
+}
+
+func TestDecodeOrderCursorInvalid(t *testing.T) {
+	enc := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"not base64", "%%%"},
+		{"padded base64", base64.URLEncoding.EncodeToString([]byte("2024-05-01T00:00:00Z|a"))},
+		{"no separator", enc("2024-05-01T00:00:00Z")},
+		{"bad time", enc("yesterday|a")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := decodeOrderCursor(tt.cursor); !errors.Is(err, ErrInvalidCursor) {
+				t.Fatalf("got %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ReserveLine is one hub/SKU quantity sent to IMS
+type ReserveLine struct {
+	HubCode  string `json:"hub_code"`
+	SKUCode  string `json:"sku_code"`
+	Quantity int64  `json:"quantity"`
+}
+
+// ShortLine is a line IMS could not fulfil
+type ShortLine struct {
+	HubCode   string `json:"hub_code"`
+	SKUCode   string `json:"sku_code"`
+	Requested int64  `json:"requested"`
+	Available int64  `json:"available"`
+}
+
+// IMSReservation mirrors the IMS reservation response
+type IMSReservation struct {
+	ID          string        `json:"reservation_id"`
+	ReferenceID string        `json:"reference_id"`
+	Status      string        `json:"status"`
+	ExpiresAt   time.Time     `json:"expires_at"`
+	Lines       []ReserveLine `json:"lines"`
+}
+
+// ErrInsufficientInventory is returned by CreateReservation when IMS cannot hold every line
+var ErrInsufficientInventory = errors.New("insufficient inventory")
+
+// ErrReferenceHeld is returned by CreateReservation when IMS already has an
+// open hold for the reference with other lines
+var ErrReferenceHeld = errors.New("reference already has an open reservation")
+
+// ErrReservationClosed is returned when a reservation can no longer be committed
+var ErrReservationClosed = errors.New("reservation is no longer held")
+
+// ErrReservationExpired is the ErrReservationClosed of a hold that expired,
+// so its stock went back to availability rather than being consumed
+var ErrReservationExpired = fmt.Errorf("%w: it expired", ErrReservationClosed)
+
+// ErrStockAlreadyReleased is returned by ReleaseInventory when IMS already
+// returned the stock of the reference
+var ErrStockAlreadyReleased = errors.New("stock already released")
+
+// CreateReservation asks IMS to hold stock for all lines until the TTL runs
+// out. On a shortfall it returns ErrInsufficientInventory with the short lines.
+// IMS keeps one open hold per reference: asking again for the same lines
+// returns that hold, asking for other lines ErrReferenceHeld.
+func CreateReservation(ctx context.Context, baseURL, tenantID, sellerID, referenceID string, ttl time.Duration, lines []ReserveLine) (*IMSReservation, []ShortLine, error) {
+	url := fmt.Sprintf("%s/reservations", baseURL)
+	payload := map[string]interface{}{
+		"tenant_id":    tenantID,
+		"seller_id":    sellerID,
+		"reference_id": referenceID,
+		"ttl_seconds":  int64(ttl / time.Second),
+		"lines":        lines,
+	}
+
+	resp, err := postIMS(ctx, url, tenantID, payload)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusCreated, http.StatusOK:
+		var res IMSReservation
+		if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+			return nil, nil, fmt.Errorf("decode error: %w", err)
+		}
+		return &res, nil, nil
+	case http.StatusConflict:
+		var out struct {
+			ShortLines  []ShortLine     `json:"short_lines"`
+			Reservation *IMSReservation `json:"reservation"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			return nil, nil, fmt.Errorf("decode error: %w", err)
+		}
+		if out.Reservation != nil {
+			return nil, nil, fmt.Errorf("%w: %s", ErrReferenceHeld, out.Reservation.ID)
+		}
+		return nil, out.ShortLines, ErrInsufficientInventory
+	default:
+		return nil, nil, fmt.Errorf("IMS returned status %d", resp.StatusCode)
+	}
+}
+
+// CommitReservation turns a hold into a permanent stock decrement
+func CommitReservation(ctx context.Context, baseURL, reservationID string) error {
+	return closeReservation(ctx, baseURL, reservationID, "commit")
+}
+
+// ReleaseReservation returns held stock to availability
+func ReleaseReservation(ctx context.Context, baseURL, reservationID string) error {
+	return closeReservation(ctx, baseURL, reservationID, "release")
+}
+
+// ReleaseInventory returns consumed stock to IMS on-hand, the inverse of a
+// commit. IMS releases a reference once; later calls get ErrStockAlreadyReleased.
+func ReleaseInventory(ctx context.Context, baseURL, tenantID, sellerID, referenceID string, lines []ReserveLine) error {
+	url := fmt.Sprintf("%s/inventory/release", baseURL)
+	payload := map[string]interface{}{
+		"tenant_id":    tenantID,
+		"seller_id":    sellerID,
+		"reference_id": referenceID,
+		"lines":        lines,
+	}
+
+	resp, err := postIMS(ctx, url, tenantID, payload)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusConflict:
+		return ErrStockAlreadyReleased
+	default:
+		return fmt.Errorf("IMS returned status %d", resp.StatusCode)
+	}
+}
+
+func closeReservation(ctx context.Context, baseURL, reservationID, action string) error {
+	url := fmt.Sprintf("%s/reservations/%s/%s", baseURL, reservationID, action)
+
+	resp, err := postIMS(ctx, url, "", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusConflict:
+		var out struct {
+			Reservation IMSReservation `json:"reservation"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&out); err == nil && out.Reservation.Status == "expired" {
+			return ErrReservationExpired
+		}
+		return ErrReservationClosed
+	default:
+		return fmt.Errorf("IMS returned status %d", resp.StatusCode)
+	}
+}
+
+func postIMS(ctx context.Context, url, tenantID string, payload interface{}) (*http.Response, error) {
+	var body []byte
+	if payload != nil {
+		var err error
+		if body, err = json.Marshal(payload); err != nil {
+			return nil, fmt.Errorf("marshal error: %w", err)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("new request error: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := doIMS(req, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP error: %w", err)
+	}
+	return resp, nil
+}
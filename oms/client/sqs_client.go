@@ -4,15 +4,23 @@ import (
 	"context"
 	"fmt"
 	"path"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsConfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	awssqs "github.com/aws/aws-sdk-go-v2/service/sqs"
 	gcConfig "github.com/omniful/go_commons/config"
 	"github.com/omniful/go_commons/log"
 	"github.com/omniful/go_commons/sqs"
 )
 
-// SQSClient wraps the GoCommons SQS Publisher for CreateBulkOrder
+// SQSClient wraps the GoCommons SQS Publisher for CreateBulkOrder, and an
+// AWS client for what the publisher does not cover
 type SQSClient struct {
 	Publisher *sqs.Publisher
+	Queue     *awssqs.Client
+	QueueURL  string
 }
 
 // NewSQSClient initializes the SQS publisher using config
@@ -46,11 +54,44 @@ func NewSQSClient(ctx context.Context) (*SQSClient, error) {
 
 	log.DefaultLogger().Infof(" SQS Publisher initialized for queue: %s", queueName)
 
+	awsCfg, err := awsConfig.LoadDefaultConfig(ctx,
+		awsConfig.WithRegion(sqsCfg.Region),
+		awsConfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider("test", "test", "")),
+		awsConfig.WithEndpointResolver(aws.EndpointResolverFunc(
+			func(service, region string) (aws.Endpoint, error) {
+				if service == awssqs.ServiceID {
+					return aws.Endpoint{
+						URL:               sqsCfg.Endpoint,
+						HostnameImmutable: true,
+					}, nil
+				}
+				return aws.Endpoint{}, &aws.EndpointNotFoundError{}
+			},
+		)),
+	)
+	if err != nil {
+		log.DefaultLogger().Errorf("NewSQSClient: AWS config load failed: %v", err)
+		return nil, err
+	}
+
 	return &SQSClient{
 		Publisher: publisher,
+		Queue:     awssqs.NewFromConfig(awsCfg),
+		QueueURL:  queueURL,
 	}, nil
 }
 
+// ExtendVisibility keeps a received message hidden from other consumers for
+// timeout from now
+func (c *SQSClient) ExtendVisibility(ctx context.Context, receiptHandle string, timeout time.Duration) error {
+	_, err := c.Queue.ChangeMessageVisibility(ctx, &awssqs.ChangeMessageVisibilityInput{
+		QueueUrl:          aws.String(c.QueueURL),
+		ReceiptHandle:     aws.String(receiptHandle),
+		VisibilityTimeout: int32(timeout / time.Second),
+	})
+	return err
+}
+
 // PublishCreateBulkOrderEvent sends a message payload to the SQS queue
 func (c *SQSClient) PublishCreateBulkOrderEvent(ctx context.Context, payload []byte) error {
 	msg := &sqs.Message{
@@ -64,4 +105,4 @@ func (c *SQSClient) PublishCreateBulkOrderEvent(ctx context.Context, payload []b
 
 	log.DefaultLogger().Infof(" SQS message published successfully")
 	return nil
-}
\ No newline at end of file
+}
@@ -16,6 +16,7 @@ import (
 
 	"github.com/dhruv/oms/api"
 	"github.com/dhruv/oms/client"
+	"github.com/dhruv/oms/middleware"
 	"github.com/dhruv/oms/services"
 	"github.com/dhruv/oms/worker"
 )
@@ -59,6 +60,17 @@ func main() {
 	client.InitKafkaProducer(ctx)
 	log.Info(" Kafka producer initialized successfully")
 
+	// === MONGO CLIENT ===
+	if err := client.InitMongo(ctx); err != nil {
+		log.Panicf(" Failed to connect to Mongo: %v", err)
+	}
+	log.Info(" Mongo client initialized successfully")
+
+	// === MONGO INDEXES ===
+	if err := client.EnsureOrderIndexes(ctx); err != nil {
+		log.Warnf(" Failed to ensure order indexes: %v", err)
+	}
+
 	// === IMS CLIENT SETUP ===
 
 	// imsHTTP, err := commonsHttp.NewHTTPClient(
@@ -72,6 +84,7 @@ func main() {
 	// }
 
 	imsClient := client.NewIMSClient(config.GetString(ctx, "ims.base_url"))
+	client.InitIMSAuth(ctx)
 
 	log.Info(" IMS client initialized successfully")
 
@@ -85,7 +98,7 @@ func main() {
 	handlers := api.NewHandlers(orderService)
 
 	// === START WORKER ===
-	go worker.StartCSVProcessor(ctx, imsClient)
+	go worker.StartCSVProcessor(ctx, imsClient, sqsClient)
 
 	// === SERVER SETUP ===
 	port := ":" + strconv.Itoa(config.GetInt(ctx, "server.port"))
@@ -97,6 +110,7 @@ func main() {
 		false,
 		env.RequestID(),
 		env.Middleware(config.GetString(ctx, "env")),
+		middleware.Authenticate(ctx, "/health"),
 	)
 
 	// === ROUTES ===
@@ -107,4 +121,5 @@ func main() {
 	if err := srv.StartServer("oms-service"); err != nil {
 		log.Errorf("OMS shutdown error: %v", err)
 	}
+	client.CloseMongo(ctx)
 }
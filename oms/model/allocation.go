@@ -0,0 +1,66 @@
+package model
+
+import "time"
+
+// Allocation strategies, which decide the hubs an order's stock is taken from
+const (
+	// AllocationFixed only uses the order's hub_id
+	AllocationFixed = "fixed"
+	// AllocationPreferredHub uses hub_id when it has every line, otherwise
+	// the first other hub of the seller that does
+	AllocationPreferredHub = "preferred_hub"
+	// AllocationMostStock uses the hub with the most stock of the order's
+	// SKUs among those that have every line
+	AllocationMostStock = "most_stock"
+	// AllocationSplit uses a single hub when one has every line, otherwise
+	// takes each line from as many hubs as it needs
+	AllocationSplit = "split"
+)
+
+// IsValidAllocationStrategy reports whether s is a known allocation strategy
+func IsValidAllocationStrategy(s string) bool {
+	switch s {
+	case AllocationFixed, AllocationPreferredHub, AllocationMostStock, AllocationSplit:
+		return true
+	}
+	return false
+}
+
+// AllocationLine is the quantity of a SKU taken from one hub
+type AllocationLine struct {
+	HubCode  string `bson:"hub_code" json:"hub_code"`
+	SKUID    string `bson:"sku_id" json:"sku_id"`
+	Quantity int64  `bson:"quantity" json:"quantity"`
+}
+
+// Allocation records which hubs the finalizer took an order's stock from and why
+type Allocation struct {
+	Strategy  string           `bson:"strategy" json:"strategy"`
+	Hubs      []string         `bson:"hubs" json:"hubs"`
+	Split     bool             `bson:"split" json:"split"`
+	Lines     []AllocationLine `bson:"lines" json:"lines"`
+	Reason    string           `bson:"reason" json:"reason"`
+	DecidedAt time.Time        `bson:"decided_at" json:"decided_at"`
+}
+
+// Fulfillment policies, which decide what happens to an order that can only
+// partly be allocated
+const (
+	// FulfillmentComplete keeps the order on_hold until every line can be held
+	FulfillmentComplete = "complete"
+	// FulfillmentPartial holds what is available, moves the order to
+	// partially_allocated and backorders the rest
+	FulfillmentPartial = "partial"
+)
+
+// IsValidFulfillmentPolicy reports whether s is a known fulfillment policy
+func IsValidFulfillmentPolicy(s string) bool {
+	return s == FulfillmentComplete || s == FulfillmentPartial
+}
+
+// PendingFill is a partial fill whose IMS hold is recorded on the order but
+// not yet committed
+type PendingFill struct {
+	ReservationID string           `bson:"reservation_id" json:"reservation_id"`
+	Lines         []AllocationLine `bson:"lines" json:"lines"`
+}
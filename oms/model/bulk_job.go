@@ -0,0 +1,47 @@
+package model
+
+import "time"
+
+// Bulk job states
+const (
+	BulkJobQueued     = "queued"
+	BulkJobProcessing = "processing"
+	BulkJobCompleted  = "completed"
+	BulkJobFailed     = "failed"
+)
+
+// BulkJob tracks one CSV upload from the moment it is queued until the worker
+// has persisted its orders
+type BulkJob struct {
+	ID                string     `bson:"_id" json:"id"`
+	TenantID          string     `bson:"tenant_id,omitempty" json:"tenant_id,omitempty"`
+	SellerID          string     `bson:"seller_id,omitempty" json:"seller_id,omitempty"`
+	Bucket            string     `bson:"bucket" json:"bucket"`
+	FileKey           string     `bson:"file_key" json:"file_key"`
+	State             string     `bson:"state" json:"state"`
+	Total             int        `bson:"total" json:"total"`           // data rows read so far; the whole file once completed
+	Valid             int        `bson:"valid" json:"valid"`           // rows that passed validation
+	Invalid           int        `bson:"invalid" json:"invalid"`       // rows written to the error file
+	Persisted         int        `bson:"persisted" json:"persisted"`   // rows saved as part of a new order
+	Duplicates        int        `bson:"duplicates" json:"duplicates"` // rows skipped because their order already exists
+	OrdersCreated     int        `bson:"orders_created" json:"orders_created"`
+	ErrorFileKey      string     `bson:"error_file_key,omitempty" json:"error_file_key,omitempty"`
+	DuplicatesFileKey string     `bson:"duplicates_file_key,omitempty" json:"duplicates_file_key,omitempty"`
+	Error             string     `bson:"error,omitempty" json:"error,omitempty"` // why the job failed
+	CheckpointRow     int        `bson:"checkpoint_row" json:"checkpoint_row"`   // data rows fully processed; a redelivered message resumes here
+	ErrorParts        []string   `bson:"error_parts,omitempty" json:"-"`         // per-batch rejected rows, merged into ErrorFileKey at the end
+	DuplicateParts    []string   `bson:"duplicate_parts,omitempty" json:"-"`
+	CreatedAt         time.Time  `bson:"created_at" json:"created_at"`
+	StartedAt         *time.Time `bson:"started_at,omitempty" json:"started_at,omitempty"`
+	FinishedAt        *time.Time `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+	UpdatedAt         time.Time  `bson:"updated_at" json:"updated_at"`
+}
+
+// BulkJobProgress is what the CSV worker reports after each batch of rows
+type BulkJobProgress struct {
+	Valid         int
+	Invalid       int
+	Persisted     int
+	Duplicates    int
+	OrdersCreated int
+}
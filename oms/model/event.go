@@ -8,24 +8,83 @@ type CreateBulkOrderEvent struct {
 	UploadedAt string `json:"uploaded_at"`  // optional timestamp
 }
 
+// OrderLine is a single SKU and quantity within an order
+type OrderLine struct {
+	SKUID    string `bson:"sku_id" json:"sku_id"`
+	Quantity int64  `bson:"quantity" json:"quantity"`
+	// set under the partial fulfillment policy: the units held for the order
+	// and the units still waiting for stock
+	Allocated   int64 `bson:"allocated,omitempty" json:"allocated,omitempty"`
+	Backordered int64 `bson:"backordered,omitempty" json:"backordered,omitempty"`
+}
+
 
 type Order struct {
-    ID        string    `bson:"_id,omitempty"`
-    TenantID  string    `bson:"tenant_id"`
-    SellerID  string    `bson:"seller_id"`
-    HubID     string    `bson:"hub_id"`
-    SKUID     string    `bson:"sku_id"`
-    Quantity  int64     `bson:"quantity"`
-    Status    string    `bson:"status"`
-    CreatedAt time.Time `bson:"created_at"`
+	ID             string         `bson:"_id,omitempty" json:"id"`
+	OrderRef       string         `bson:"order_ref,omitempty" json:"order_ref,omitempty"`             // client reference that groups CSV rows
+	IdempotencyKey string         `bson:"idempotency_key,omitempty" json:"idempotency_key,omitempty"` // unique; derived from order_ref or file and row
+	BulkJobID      string         `bson:"bulk_job_id,omitempty" json:"bulk_job_id,omitempty"`         // bulk job whose file created the order
+	SourceRow      int            `bson:"source_row,omitempty" json:"-"`                              // first file row of the order in that job
+	TenantID       string         `bson:"tenant_id" json:"tenant_id"`
+	SellerID       string         `bson:"seller_id" json:"seller_id"`
+	HubID          string         `bson:"hub_id" json:"hub_id"`
+	Lines          []OrderLine    `bson:"lines" json:"lines"`
+	Status         string         `bson:"status" json:"status"`
+	StatusHistory  []StatusChange `bson:"status_history" json:"status_history"`
+	ReservationID  string         `bson:"reservation_id,omitempty" json:"reservation_id,omitempty"`       // IMS hold taken at finalization
+	Allocation     *Allocation    `bson:"allocation,omitempty" json:"allocation,omitempty"`               // hubs the stock was taken from
+	PendingFill    *PendingFill   `bson:"pending_fill,omitempty" json:"pending_fill,omitempty"`           // partial fill held but not yet committed
+	FillVersion    int64          `bson:"fill_version,omitempty" json:"-"`                                // bumped by every partial fill
+	AnnouncedAt    *time.Time     `bson:"announced_at,omitempty" json:"-"`                                // set once order.created went out
+	StockReleased  *time.Time     `bson:"stock_released_at,omitempty" json:"stock_released_at,omitempty"` // set once a cancellation returned stock to IMS
+	ReleaseClaim   *time.Time     `bson:"stock_release_claimed_until,omitempty" json:"-"`                 // a cancellation is returning the stock until then
+	CreatedAt      time.Time      `bson:"created_at" json:"created_at"`
 }
 
 type OrderCreated struct {
-	OrderID   string    `json:"order_id"`
-	TenantID  string    `json:"tenant_id"`
-	SellerID  string    `json:"seller_id"`
-	HubCode     string    `json:"hub_id"`
-	SKUCode    string    `json:"sku_id"`
-	Quantity  int64     `json:"quantity"`
-	CreatedAt time.Time `json:"created_at"`
-}
\ No newline at end of file
+	OrderID   string      `json:"order_id"`
+	OrderRef  string      `json:"order_ref,omitempty"`
+	TenantID  string      `json:"tenant_id"`
+	SellerID  string      `json:"seller_id"`
+	HubCode   string      `json:"hub_id"`
+	Lines     []OrderLine `json:"lines"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+// NewOrderCreated builds the order.created payload for an order
+func NewOrderCreated(o *Order) OrderCreated {
+	return OrderCreated{
+		OrderID:   o.ID,
+		OrderRef:  o.OrderRef,
+		TenantID:  o.TenantID,
+		SellerID:  o.SellerID,
+		HubCode:   o.HubID,
+		Lines:     o.Lines,
+		CreatedAt: o.CreatedAt,
+	}
+}
+
+// OrderCancelled is published when an order is cancelled
+type OrderCancelled struct {
+	OrderID        string      `json:"order_id"`
+	OrderRef       string      `json:"order_ref,omitempty"`
+	TenantID       string      `json:"tenant_id"`
+	SellerID       string      `json:"seller_id"`
+	HubCode        string      `json:"hub_id"`
+	Lines          []OrderLine `json:"lines"`
+	PreviousStatus string      `json:"previous_status"`
+	Reason         string      `json:"reason,omitempty"`
+	StockReleased  bool        `json:"stock_released"`
+	CancelledAt    time.Time   `json:"cancelled_at"`
+}
+
+// InventoryUpdated is consumed from IMS when available stock for a hub/SKU goes up
+type InventoryUpdated struct {
+	TenantID   string    `json:"tenant_id"`
+	SellerID   string    `json:"seller_id"`
+	HubCode    string    `json:"hub_code"`
+	SKUCode    string    `json:"sku_code"`
+	Delta      int64     `json:"delta"`
+	Reason     string    `json:"reason"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
@@ -0,0 +1,68 @@
+package model
+
+import "time"
+
+// Order statuses
+const (
+	OrderStatusOnHold             = "on_hold"
+	OrderStatusPartiallyAllocated = "partially_allocated"
+	OrderStatusNewOrder           = "new_order"
+	OrderStatusPacked             = "packed"
+	OrderStatusShipped            = "shipped"
+	OrderStatusDelivered          = "delivered"
+	OrderStatusCancelled          = "cancelled"
+	OrderStatusReturned           = "returned"
+)
+
+// orderTransitions lists the statuses each status may move to.
+// cancelled and returned are terminal.
+var orderTransitions = map[string][]string{
+	// new_order -> on_hold and the moves back from new_order or
+	// partially_allocated happen when an inventory hold lapses before commit
+	OrderStatusOnHold:             {OrderStatusNewOrder, OrderStatusPartiallyAllocated, OrderStatusCancelled},
+	OrderStatusPartiallyAllocated: {OrderStatusNewOrder, OrderStatusCancelled, OrderStatusOnHold},
+	OrderStatusNewOrder:           {OrderStatusPacked, OrderStatusCancelled, OrderStatusOnHold, OrderStatusPartiallyAllocated},
+	OrderStatusPacked:             {OrderStatusShipped, OrderStatusCancelled},
+	OrderStatusShipped:            {OrderStatusDelivered, OrderStatusReturned},
+	OrderStatusDelivered:          {OrderStatusReturned},
+}
+
+// StatusChange is one entry in an order's status_history
+type StatusChange struct {
+	From   string    `bson:"from,omitempty" json:"from,omitempty"`
+	To     string    `bson:"to" json:"to"`
+	At     time.Time `bson:"at" json:"at"`
+	Actor  string    `bson:"actor" json:"actor"`
+	Reason string    `bson:"reason,omitempty" json:"reason,omitempty"`
+}
+
+// IsValidOrderStatus reports whether s is a known order status
+func IsValidOrderStatus(s string) bool {
+	if _, ok := orderTransitions[s]; ok {
+		return true
+	}
+	return s == OrderStatusCancelled || s == OrderStatusReturned
+}
+
+// CanTransition reports whether an order may move from one status to another
+func CanTransition(from, to string) bool {
+	for _, next := range orderTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// StatusesLeadingTo returns every status that may move directly to the given one
+func StatusesLeadingTo(to string) []string {
+	var from []string
+	for s, nexts := range orderTransitions {
+		for _, next := range nexts {
+			if next == to {
+				from = append(from, s)
+			}
+		}
+	}
+	return from
+}
@@ -0,0 +1,53 @@
+package model
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{OrderStatusOnHold, OrderStatusNewOrder, true},
+		{OrderStatusOnHold, OrderStatusPartiallyAllocated, true},
+		{OrderStatusOnHold, OrderStatusPacked, false},
+		{OrderStatusPartiallyAllocated, OrderStatusNewOrder, true},
+		{OrderStatusPartiallyAllocated, OrderStatusOnHold, true},
+		{OrderStatusNewOrder, OrderStatusPacked, true},
+		{OrderStatusNewOrder, OrderStatusOnHold, true},
+		{OrderStatusNewOrder, OrderStatusShipped, false},
+		{OrderStatusPacked, OrderStatusShipped, true},
+		{OrderStatusPacked, OrderStatusNewOrder, false},
+		{OrderStatusShipped, OrderStatusDelivered, true},
+		{OrderStatusShipped, OrderStatusCancelled, false},
+		{OrderStatusDelivered, OrderStatusReturned, true},
+		{OrderStatusNewOrder, OrderStatusNewOrder, false},
+		// terminal
+		{OrderStatusCancelled, OrderStatusNewOrder, false},
+		{OrderStatusReturned, OrderStatusDelivered, false},
+		// unknown
+		{"lost", OrderStatusNewOrder, false},
+		{OrderStatusNewOrder, "lost", false},
+	}
+	for _, tt := range tests {
+		if got := CanTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransition(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestStatusesLeadingTo(t *testing.T) {
+	got := StatusesLeadingTo(OrderStatusCancelled)
+	sort.Strings(got)
+	want := []string{OrderStatusNewOrder, OrderStatusOnHold, OrderStatusPacked, OrderStatusPartiallyAllocated}
+	if len(got) != len(want) {
+		t.Fatalf("StatusesLeadingTo(cancelled) = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("StatusesLeadingTo(cancelled) = %v, want %v", got, want)
+		}
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/omniful/go_commons/log"
 
 	"github.com/dhruv/oms/client"
+	"github.com/dhruv/oms/model"
 )
 
 // OrderService handles order-related logic
@@ -26,8 +27,9 @@ func NewOrderService(s3Client *client.S3Client, sqsClient *client.SQSClient) *Or
 	}
 }
 
-// ProcessCSV validates S3 path and pushes SQS event
-func (s *OrderService) ProcessCSV(ctx context.Context, s3Path string) error {
+// ProcessCSV validates S3 path, records a bulk job and pushes SQS event.
+// tenantID and sellerID are optional and only label the job.
+func (s *OrderService) ProcessCSV(ctx context.Context, s3Path, tenantID, sellerID string) (*model.BulkJob, error) {
 	log.Infof(" Validating S3 path: %s", s3Path)
 
 	_, err := s.S3.Client.HeadObject(ctx, &s3.HeadObjectInput{
@@ -36,28 +38,42 @@ func (s *OrderService) ProcessCSV(ctx context.Context, s3Path string) error {
 	})
 	if err != nil {
 		log.Errorf(" S3 HeadObject failed: %v", err)
-		return fmt.Errorf("failed to validate S3 path %s: %w", s3Path, err)
+		return nil, fmt.Errorf("failed to validate S3 path %s: %w", s3Path, err)
 	}
 
 	log.Infof(" S3 file exists: %s", s3Path)
 
+	job := &model.BulkJob{
+		TenantID: tenantID,
+		SellerID: sellerID,
+		Bucket:   config.GetString(ctx, "s3.bucket"),
+		FileKey:  s3Path,
+	}
+	if err := client.CreateBulkJob(ctx, job); err != nil {
+		return nil, fmt.Errorf("failed to create bulk job: %w", err)
+	}
+
 	//  Create payload that CSV worker expects
 	payload := map[string]string{
-		"Bucket": config.GetString(ctx, "s3.bucket"),
+		"Bucket": job.Bucket,
 		"Key":    s3Path,
+		"JobID":  job.ID,
 	}
 
 	data, err := json.Marshal(payload)
 	if err != nil {
 		log.Errorf(" Failed to marshal SQS payload: %v", err)
-		return fmt.Errorf("failed to marshal payload: %w", err)
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
 	}
 
 	if err := s.SQSClient.PublishCreateBulkOrderEvent(ctx, data); err != nil {
 		log.Errorf(" Failed to publish SQS event: %v", err)
-		return fmt.Errorf("failed to publish event to SQS: %w", err)
+		if ferr := client.FailBulkJob(ctx, job.ID, "failed to queue file"); ferr != nil {
+			log.Errorf(" Failed to mark bulk job %s failed: %v", job.ID, ferr)
+		}
+		return nil, fmt.Errorf("failed to publish event to SQS: %w", err)
 	}
 
 	log.Infof(" CreateBulkOrderEvent published to SQS: %v", payload)
-	return nil
+	return job, nil
 }
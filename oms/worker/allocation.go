@@ -0,0 +1,253 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/omniful/go_commons/config"
+
+	"github.com/dhruv/oms/client"
+	"github.com/dhruv/oms/model"
+)
+
+const defaultAllocationStrategy = model.AllocationPreferredHub
+
+// allocationStrategy returns the strategy for a tenant: its entry in
+// allocation.tenant_strategies, else allocation.strategy, else preferred_hub
+func allocationStrategy(ctx context.Context, tenantID string) string {
+	if s := config.GetStringMapString(ctx, "allocation.tenant_strategies")[tenantID]; model.IsValidAllocationStrategy(s) {
+		return s
+	}
+	if s := config.GetString(ctx, "allocation.strategy"); model.IsValidAllocationStrategy(s) {
+		return s
+	}
+	return defaultAllocationStrategy
+}
+
+// hubStock is the available stock of an order's SKUs across the seller's hubs
+type hubStock struct {
+	hubs      []string                    // the preferred hub first, then the others by code
+	available map[string]map[string]int64 // hub -> SKU -> available
+}
+
+func newHubStock(preferredHub string, rows []client.IMSInventory) *hubStock {
+	s := &hubStock{available: map[string]map[string]int64{preferredHub: {}}}
+	for _, r := range rows {
+		if s.available[r.HubCode] == nil {
+			s.available[r.HubCode] = map[string]int64{}
+		}
+		if r.Available > 0 {
+			s.available[r.HubCode][r.SKUCode] += r.Available
+		}
+	}
+
+	for hub := range s.available {
+		if hub != preferredHub {
+			s.hubs = append(s.hubs, hub)
+		}
+	}
+	sort.Strings(s.hubs)
+	s.hubs = append([]string{preferredHub}, s.hubs...)
+	return s
+}
+
+// fulfils reports whether hub has every line
+func (s *hubStock) fulfils(hub string, lines []model.OrderLine) bool {
+	for _, l := range lines {
+		if s.available[hub][l.SKUID] < l.Quantity {
+			return false
+		}
+	}
+	return true
+}
+
+// total is the stock hub has of the order's SKUs
+func (s *hubStock) total(hub string, lines []model.OrderLine) int64 {
+	var n int64
+	for _, l := range lines {
+		n += s.available[hub][l.SKUID]
+	}
+	return n
+}
+
+// allocate picks the hubs an order's lines are taken from. When the strategy
+// finds none it returns the lines each hub is short of, with a nil allocation.
+func allocate(strategy, preferredHub string, lines []model.OrderLine, stock *hubStock) (*model.Allocation, []client.ShortLine) {
+	candidates := stock.hubs
+	if strategy == model.AllocationFixed {
+		candidates = candidates[:1]
+	}
+
+	var hub, reason string
+	switch strategy {
+	case model.AllocationMostStock:
+		for _, h := range candidates {
+			if stock.fulfils(h, lines) && (hub == "" || stock.total(h, lines) > stock.total(hub, lines)) {
+				hub = h
+			}
+		}
+		reason = fmt.Sprintf("hub %s has the most stock of the order's SKUs", hub)
+	default:
+		for _, h := range candidates {
+			if stock.fulfils(h, lines) {
+				hub = h
+				break
+			}
+		}
+		reason = fmt.Sprintf("preferred hub %s is short; hub %s has every line", preferredHub, hub)
+	}
+	if hub == preferredHub {
+		reason = fmt.Sprintf("preferred hub %s has every line", preferredHub)
+	}
+
+	if hub != "" {
+		allocation := &model.Allocation{Strategy: strategy, Hubs: []string{hub}, Reason: reason}
+		for _, l := range lines {
+			allocation.Lines = append(allocation.Lines, model.AllocationLine{HubCode: hub, SKUID: l.SKUID, Quantity: l.Quantity})
+		}
+		return allocation, nil
+	}
+
+	if strategy == model.AllocationSplit {
+		if allocation := splitAllocation(lines, stock); allocation != nil {
+			return allocation, nil
+		}
+	}
+	return nil, shortAt(candidates, lines, stock)
+}
+
+// splitAllocation takes each line from the preferred hub first and then from
+// the hubs with the most of that SKU, or returns nil if the seller's hubs
+// together cannot cover every line
+func splitAllocation(lines []model.OrderLine, stock *hubStock) *model.Allocation {
+	taken, hubs, complete := spreadLines(lines, stock, stock.hubs)
+	if !complete {
+		return nil
+	}
+	return &model.Allocation{
+		Strategy: model.AllocationSplit,
+		Hubs:     hubs,
+		Split:    true,
+		Lines:    taken,
+		Reason:   fmt.Sprintf("no single hub has every line; split across %d hubs", len(hubs)),
+	}
+}
+
+// spreadLines takes as much of each line as it can from candidates: the
+// preferred hub first, then the others with the most of that SKU. complete
+// reports whether every line was covered in full.
+func spreadLines(lines []model.OrderLine, stock *hubStock, candidates []string) (taken []model.AllocationLine, hubs []string, complete bool) {
+	used := map[string]bool{}
+	complete = true
+
+	for _, l := range lines {
+		others := append([]string(nil), candidates[1:]...)
+		sort.SliceStable(others, func(i, j int) bool {
+			return stock.available[others[i]][l.SKUID] > stock.available[others[j]][l.SKUID]
+		})
+
+		left := l.Quantity
+		for _, hub := range append(candidates[:1:1], others...) {
+			take := stock.available[hub][l.SKUID]
+			if take > left {
+				take = left
+			}
+			if take <= 0 {
+				continue
+			}
+			taken = append(taken, model.AllocationLine{HubCode: hub, SKUID: l.SKUID, Quantity: take})
+			if !used[hub] {
+				used[hub] = true
+				hubs = append(hubs, hub)
+			}
+			left -= take
+			if left == 0 {
+				break
+			}
+		}
+		if left > 0 {
+			complete = false
+		}
+	}
+	return taken, hubs, complete
+}
+
+// partialAllocation takes whatever the strategy's candidate hubs have of the
+// lines, or returns nil when they have none of it
+func partialAllocation(strategy string, lines []model.OrderLine, stock *hubStock) *model.Allocation {
+	candidates := stock.hubs
+	if strategy == model.AllocationFixed {
+		candidates = candidates[:1]
+	}
+
+	taken, hubs, _ := spreadLines(lines, stock, candidates)
+	if len(taken) == 0 {
+		return nil
+	}
+
+	var want, got int64
+	for _, l := range lines {
+		want += l.Quantity
+	}
+	for _, l := range taken {
+		got += l.Quantity
+	}
+	return &model.Allocation{
+		Strategy: strategy,
+		Hubs:     hubs,
+		Split:    len(hubs) > 1,
+		Lines:    taken,
+		Reason:   fmt.Sprintf("partial: %d of %d units available across %d hubs", got, want, len(hubs)),
+	}
+}
+
+// shortAt lists, for every candidate hub, the lines it does not have enough of
+func shortAt(hubs []string, lines []model.OrderLine, stock *hubStock) []client.ShortLine {
+	var short []client.ShortLine
+	for _, hub := range hubs {
+		for _, l := range lines {
+			if have := stock.available[hub][l.SKUID]; have < l.Quantity {
+				short = append(short, client.ShortLine{HubCode: hub, SKUCode: l.SKUID, Requested: l.Quantity, Available: have})
+			}
+		}
+	}
+	return short
+}
+
+// allocateOrder decides where an order's stock comes from. The fixed strategy
+// needs no stock lookup: the reservation itself reports what is short. With
+// partial set, an order no hub can fully cover gets whatever is available
+// instead of a nil allocation; the short lines are still returned.
+func allocateOrder(ctx context.Context, baseURL string, event model.OrderCreated, partial bool) (*model.Allocation, []client.ShortLine, error) {
+	strategy := allocationStrategy(ctx, event.TenantID)
+
+	var allocation *model.Allocation
+	var short []client.ShortLine
+	if strategy == model.AllocationFixed && !partial {
+		allocation = &model.Allocation{Strategy: strategy, Hubs: []string{event.HubCode}, Reason: fmt.Sprintf("hub %s is fixed", event.HubCode)}
+		for _, l := range event.Lines {
+			allocation.Lines = append(allocation.Lines, model.AllocationLine{HubCode: event.HubCode, SKUID: l.SKUID, Quantity: l.Quantity})
+		}
+	} else {
+		skus := make([]string, 0, len(event.Lines))
+		for _, l := range event.Lines {
+			skus = append(skus, l.SKUID)
+		}
+		rows, err := client.FetchAvailability(ctx, baseURL, event.TenantID, event.SellerID, skus)
+		if err != nil {
+			return nil, nil, err
+		}
+		stock := newHubStock(event.HubCode, rows)
+		allocation, short = allocate(strategy, event.HubCode, event.Lines, stock)
+		if allocation == nil && partial {
+			allocation = partialAllocation(strategy, event.Lines, stock)
+		}
+	}
+
+	if allocation != nil {
+		allocation.DecidedAt = time.Now().UTC()
+	}
+	return allocation, short, nil
+}
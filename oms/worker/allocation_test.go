@@ -0,0 +1,186 @@
+package worker
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dhruv/oms/client"
+	"github.com/dhruv/oms/model"
+)
+
+// testStock has H1 as the preferred hub: it has 2 A and 1 B. H2 has 5 A and
+// 1 B, H3 has 5 A and 5 B.
+func testStock() *hubStock {
+	return newHubStock("H1", []client.IMSInventory{
+		{HubCode: "H3", SKUCode: "A", Available: 5},
+		{HubCode: "H3", SKUCode: "B", Available: 5},
+		{HubCode: "H1", SKUCode: "A", Available: 2},
+		{HubCode: "H1", SKUCode: "B", Available: 1},
+		{HubCode: "H2", SKUCode: "A", Available: 5},
+		{HubCode: "H2", SKUCode: "B", Available: 1},
+		{HubCode: "H2", SKUCode: "C", Available: -3},
+	})
+}
+
+func TestNewHubStock(t *testing.T) {
+	s := testStock()
+	if want := []string{"H1", "H2", "H3"}; !reflect.DeepEqual(s.hubs, want) {
+		t.Fatalf("hubs = %v, want %v", s.hubs, want)
+	}
+	if got := s.available["H2"]["C"]; got != 0 {
+		t.Fatalf("negative stock counted as %d, want 0", got)
+	}
+
+	// a preferred hub with no stock still comes first
+	if s := newHubStock("H9", nil); !reflect.DeepEqual(s.hubs, []string{"H9"}) {
+		t.Fatalf("hubs = %v, want [H9]", s.hubs)
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	tests := []struct {
+		name      string
+		strategy  string
+		lines     []model.OrderLine
+		wantHubs  []string // nil when nothing is allocated
+		wantLines []model.AllocationLine
+		wantShort []client.ShortLine
+	}{
+		{
+			name:      "preferred hub has every line",
+			strategy:  model.AllocationPreferredHub,
+			lines:     []model.OrderLine{{SKUID: "A", Quantity: 2}, {SKUID: "B", Quantity: 1}},
+			wantHubs:  []string{"H1"},
+			wantLines: []model.AllocationLine{{HubCode: "H1", SKUID: "A", Quantity: 2}, {HubCode: "H1", SKUID: "B", Quantity: 1}},
+		},
+		{
+			name:      "preferred hub short, first other hub by code",
+			strategy:  model.AllocationPreferredHub,
+			lines:     []model.OrderLine{{SKUID: "A", Quantity: 3}, {SKUID: "B", Quantity: 1}},
+			wantHubs:  []string{"H2"},
+			wantLines: []model.AllocationLine{{HubCode: "H2", SKUID: "A", Quantity: 3}, {HubCode: "H2", SKUID: "B", Quantity: 1}},
+		},
+		{
+			name:     "fixed only uses the preferred hub",
+			strategy: model.AllocationFixed,
+			lines:    []model.OrderLine{{SKUID: "A", Quantity: 3}, {SKUID: "B", Quantity: 1}},
+			wantShort: []client.ShortLine{
+				{HubCode: "H1", SKUCode: "A", Requested: 3, Available: 2},
+			},
+		},
+		{
+			name:      "most stock",
+			strategy:  model.AllocationMostStock,
+			lines:     []model.OrderLine{{SKUID: "A", Quantity: 1}, {SKUID: "B", Quantity: 1}},
+			wantHubs:  []string{"H3"},
+			wantLines: []model.AllocationLine{{HubCode: "H3", SKUID: "A", Quantity: 1}, {HubCode: "H3", SKUID: "B", Quantity: 1}},
+		},
+		{
+			name:      "split prefers a single hub",
+			strategy:  model.AllocationSplit,
+			lines:     []model.OrderLine{{SKUID: "B", Quantity: 4}},
+			wantHubs:  []string{"H3"},
+			wantLines: []model.AllocationLine{{HubCode: "H3", SKUID: "B", Quantity: 4}},
+		},
+		{
+			name:     "split across hubs",
+			strategy: model.AllocationSplit,
+			lines:    []model.OrderLine{{SKUID: "A", Quantity: 9}},
+			wantHubs: []string{"H1", "H2", "H3"},
+			wantLines: []model.AllocationLine{
+				{HubCode: "H1", SKUID: "A", Quantity: 2},
+				{HubCode: "H2", SKUID: "A", Quantity: 5},
+				{HubCode: "H3", SKUID: "A", Quantity: 2},
+			},
+		},
+		{
+			name:     "nothing covers the order",
+			strategy: model.AllocationSplit,
+			lines:    []model.OrderLine{{SKUID: "B", Quantity: 8}},
+			wantShort: []client.ShortLine{
+				{HubCode: "H1", SKUCode: "B", Requested: 8, Available: 1},
+				{HubCode: "H2", SKUCode: "B", Requested: 8, Available: 1},
+				{HubCode: "H3", SKUCode: "B", Requested: 8, Available: 5},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allocation, short := allocate(tt.strategy, "H1", tt.lines, testStock())
+			if tt.wantHubs == nil {
+				if allocation != nil {
+					t.Fatalf("got allocation %+v, want none", allocation)
+				}
+				if !reflect.DeepEqual(short, tt.wantShort) {
+					t.Fatalf("short = %+v, want %+v", short, tt.wantShort)
+				}
+				return
+			}
+
+			if allocation == nil {
+				t.Fatalf("got no allocation, short %+v", short)
+			}
+			if allocation.Strategy != tt.strategy || !reflect.DeepEqual(allocation.Hubs, tt.wantHubs) || allocation.Split != (len(tt.wantHubs) > 1) {
+				t.Fatalf("got strategy=%s hubs=%v split=%v, want %s %v", allocation.Strategy, allocation.Hubs, allocation.Split, tt.strategy, tt.wantHubs)
+			}
+			if !reflect.DeepEqual(allocation.Lines, tt.wantLines) {
+				t.Fatalf("lines = %+v, want %+v", allocation.Lines, tt.wantLines)
+			}
+		})
+	}
+}
+
+func TestSpreadLines(t *testing.T) {
+	tests := []struct {
+		name         string
+		lines        []model.OrderLine
+		candidates   []string
+		wantTaken    []model.AllocationLine
+		wantHubs     []string
+		wantComplete bool
+	}{
+		{
+			name:         "preferred hub first, then most of the SKU",
+			lines:        []model.OrderLine{{SKUID: "B", Quantity: 3}},
+			candidates:   []string{"H1", "H2", "H3"},
+			wantTaken:    []model.AllocationLine{{HubCode: "H1", SKUID: "B", Quantity: 1}, {HubCode: "H3", SKUID: "B", Quantity: 2}},
+			wantHubs:     []string{"H1", "H3"},
+			wantComplete: true,
+		},
+		{
+			name:       "hubs are listed once",
+			lines:      []model.OrderLine{{SKUID: "A", Quantity: 4}, {SKUID: "B", Quantity: 2}},
+			candidates: []string{"H1", "H2", "H3"},
+			wantTaken: []model.AllocationLine{
+				{HubCode: "H1", SKUID: "A", Quantity: 2},
+				{HubCode: "H2", SKUID: "A", Quantity: 2},
+				{HubCode: "H1", SKUID: "B", Quantity: 1},
+				{HubCode: "H3", SKUID: "B", Quantity: 1},
+			},
+			wantHubs:     []string{"H1", "H2", "H3"},
+			wantComplete: true,
+		},
+		{
+			name:         "short takes what there is",
+			lines:        []model.OrderLine{{SKUID: "A", Quantity: 4}, {SKUID: "C", Quantity: 1}},
+			candidates:   []string{"H1"},
+			wantTaken:    []model.AllocationLine{{HubCode: "H1", SKUID: "A", Quantity: 2}},
+			wantHubs:     []string{"H1"},
+			wantComplete: false,
+		},
+		{
+			name:         "none anywhere",
+			lines:        []model.OrderLine{{SKUID: "C", Quantity: 1}},
+			candidates:   []string{"H1", "H2", "H3"},
+			wantComplete: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			taken, hubs, complete := spreadLines(tt.lines, testStock(), tt.candidates)
+			if !reflect.DeepEqual(taken, tt.wantTaken) || !reflect.DeepEqual(hubs, tt.wantHubs) || complete != tt.wantComplete {
+				t.Fatalf("got (%+v, %v, %v), want (%+v, %v, %v)", taken, hubs, complete, tt.wantTaken, tt.wantHubs, tt.wantComplete)
+			}
+		})
+	}
+}
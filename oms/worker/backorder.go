@@ -0,0 +1,283 @@
+package worker
+
+import (
+	"context"
+	"errors"
+
+	"github.com/omniful/go_commons/config"
+	"github.com/omniful/go_commons/log"
+
+	"github.com/dhruv/oms/client"
+	"github.com/dhruv/oms/model"
+)
+
+const defaultFulfillmentPolicy = model.FulfillmentComplete
+
+// fulfillmentPolicy returns the policy for a tenant: its entry in
+// fulfillment.tenant_policies, else fulfillment.policy, else complete
+func fulfillmentPolicy(ctx context.Context, tenantID string) string {
+	if p := config.GetStringMapString(ctx, "fulfillment.tenant_policies")[tenantID]; model.IsValidFulfillmentPolicy(p) {
+		return p
+	}
+	if p := config.GetString(ctx, "fulfillment.policy"); model.IsValidFulfillmentPolicy(p) {
+		return p
+	}
+	return defaultFulfillmentPolicy
+}
+
+// finalizePartial holds whatever stock there is for the units of an order
+// that are not allocated yet. The order moves to new_order once every unit
+// is held and to partially_allocated while some are still backordered; the
+// lines the seller's hubs are short of are returned with a nil error.
+//
+// Each fill is recorded on the order as pending before its hold is
+// committed, so a fill interrupted in between is settled by the next attempt.
+func finalizePartial(ctx context.Context, baseURL, orderID string) ([]client.ShortLine, error) {
+	logger := log.DefaultLogger()
+
+	order, err := settlePendingFill(ctx, baseURL, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order.Status != model.OrderStatusOnHold && order.Status != model.OrderStatusPartiallyAllocated {
+		logger.Infof(" Order %s is %s, nothing to finalize", orderID, order.Status)
+		return nil, nil
+	}
+
+	event := model.NewOrderCreated(order)
+	event.Lines = backorderedLines(order.Lines)
+	allocation, short, err := allocateOrder(ctx, baseURL, event, true)
+	if err != nil {
+		logger.Errorf(" IMS availability lookup for order %s failed: %v", orderID, err)
+		return nil, err
+	}
+	if allocation == nil {
+		logger.Warnf(" Order %s kept %s, no stock for its backorder: %+v", orderID, order.Status, short)
+		return short, nil
+	}
+	logger.Infof(" Order %s allocated (%s): %s", orderID, allocation.Strategy, allocation.Reason)
+
+	lines := make([]client.ReserveLine, 0, len(allocation.Lines))
+	for _, line := range allocation.Lines {
+		lines = append(lines, client.ReserveLine{HubCode: line.HubCode, SKUCode: line.SKUID, Quantity: line.Quantity})
+	}
+
+	reservation, reserveShort, err := client.CreateReservation(ctx, baseURL, order.TenantID, order.SellerID, orderID, config.GetDuration(ctx, "ims.reservation_ttl"), lines)
+	if errors.Is(err, client.ErrInsufficientInventory) {
+		// the stock went to someone else between the lookup and the hold
+		logger.Warnf(" Order %s kept %s due to insufficient inventory: %+v", orderID, order.Status, reserveShort)
+		return reserveShort, nil
+	}
+	if errors.Is(err, client.ErrReferenceHeld) {
+		// another consumer is filling the backorder with a different allocation
+		logger.Infof(" Order %s is being filled elsewhere: %v", orderID, err)
+		return nil, nil
+	}
+	if err != nil {
+		logger.Errorf(" IMS reserve inventory failed: %v", err)
+		return nil, err
+	}
+
+	filled := applyFill(order.Lines, allocation.Lines, 1)
+	if err := client.RecordFill(ctx, client.RecordFillRequest{
+		OrderID:     orderID,
+		From:        order.Status,
+		Status:      fillStatus(filled),
+		FillVersion: order.FillVersion,
+		Lines:       filled,
+		Allocation:  mergeAllocation(order.Allocation, allocation),
+		PendingFill: &model.PendingFill{ReservationID: reservation.ID, Lines: allocation.Lines},
+		Actor:       client.ActorFinalizer,
+		Reason:      allocation.Reason,
+	}); err != nil {
+		logger.Errorf(" Failed to record fill for order %s: %v", orderID, err)
+		if errors.Is(err, client.ErrIllegalTransition) {
+			// another consumer filled or cancelled the order first, maybe
+			// with this same hold, which is then not ours to give back
+			current, getErr := client.GetOrderByID(ctx, orderID)
+			if getErr != nil {
+				return nil, getErr
+			}
+			if current.ReservationID != reservation.ID {
+				if relErr := client.ReleaseReservation(ctx, baseURL, reservation.ID); relErr != nil {
+					logger.Errorf(" IMS release reservation %s failed: %v", reservation.ID, relErr)
+				}
+			}
+			return nil, nil
+		}
+		if relErr := client.ReleaseReservation(ctx, baseURL, reservation.ID); relErr != nil {
+			logger.Errorf(" IMS release reservation %s failed: %v", reservation.ID, relErr)
+		}
+		return nil, err
+	}
+
+	if order, err = settlePendingFill(ctx, baseURL, orderID); err != nil {
+		return nil, err
+	}
+	logger.Infof(" Order %s is %s", orderID, order.Status)
+
+	// lines carry the allocated and backordered units; deliveries outlive ctx
+	client.NotifyWebhooks(context.WithoutCancel(ctx), order.TenantID, "order.updated", order)
+	return short, nil
+}
+
+// settlePendingFill commits the order's pending fill, if any, and returns the
+// order as it stands afterwards. A hold that lapsed before the commit has
+// already given its stock back, so its units return to the backorder.
+func settlePendingFill(ctx context.Context, baseURL, orderID string) (*model.Order, error) {
+	logger := log.DefaultLogger()
+
+	order, err := client.GetOrderByID(ctx, orderID)
+	if err != nil {
+		logger.Errorf(" Failed to load order %s: %v", orderID, err)
+		return nil, err
+	}
+	if order.PendingFill == nil {
+		return order, nil
+	}
+
+	reservationID := order.PendingFill.ReservationID
+	err = client.CommitReservation(ctx, baseURL, reservationID)
+	switch {
+	case errors.Is(err, client.ErrReservationClosed):
+		logger.Warnf(" Reservation %s for order %s is no longer held, backordering its units again", reservationID, orderID)
+		lines := applyFill(order.Lines, order.PendingFill.Lines, -1)
+		err = client.RecordFill(ctx, client.RecordFillRequest{
+			OrderID:     orderID,
+			From:        order.Status,
+			Status:      fillStatus(lines),
+			FillVersion: order.FillVersion,
+			Lines:       lines,
+			Allocation:  subtractAllocation(order.Allocation, order.PendingFill.Lines),
+			Actor:       client.ActorFinalizer,
+			Reason:      "inventory hold expired before commit",
+		})
+		if errors.Is(err, client.ErrIllegalTransition) {
+			// cancelled meanwhile; the cancellation accounts for the stock
+			err = nil
+		}
+	case err != nil:
+		logger.Errorf(" IMS commit reservation %s failed: %v", reservationID, err)
+	default:
+		err = client.ClearPendingFill(ctx, orderID, reservationID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return client.GetOrderByID(ctx, orderID)
+}
+
+// backorderedLines are the units of each line not allocated yet
+func backorderedLines(lines []model.OrderLine) []model.OrderLine {
+	var rest []model.OrderLine
+	for _, l := range lines {
+		if n := l.Quantity - l.Allocated; n > 0 {
+			rest = append(rest, model.OrderLine{SKUID: l.SKUID, Quantity: n})
+		}
+	}
+	return rest
+}
+
+// applyFill adds (sign 1) or takes back (sign -1) a fill's units on a copy of
+// the order's lines and recomputes what is backordered
+func applyFill(lines []model.OrderLine, fill []model.AllocationLine, sign int64) []model.OrderLine {
+	out := make([]model.OrderLine, len(lines))
+	copy(out, lines)
+	for _, f := range fill {
+		for i := range out {
+			if out[i].SKUID == f.SKUID {
+				out[i].Allocated += sign * f.Quantity
+				break
+			}
+		}
+	}
+	for i := range out {
+		out[i].Backordered = out[i].Quantity - out[i].Allocated
+	}
+	return out
+}
+
+// fillStatus is new_order when every unit is allocated, on_hold when none is
+// and partially_allocated otherwise
+func fillStatus(lines []model.OrderLine) string {
+	var allocated, backordered int64
+	for _, l := range lines {
+		allocated += l.Allocated
+		backordered += l.Backordered
+	}
+	switch {
+	case backordered == 0:
+		return model.OrderStatusNewOrder
+	case allocated == 0:
+		return model.OrderStatusOnHold
+	}
+	return model.OrderStatusPartiallyAllocated
+}
+
+// mergeAllocation adds a fill to the order's allocation so far
+func mergeAllocation(prev, next *model.Allocation) *model.Allocation {
+	if prev == nil {
+		return next
+	}
+	merged := *next
+	merged.Lines = addAllocationLines(prev.Lines, next.Lines, 1)
+	merged.Hubs = allocationHubs(merged.Lines)
+	merged.Split = len(merged.Hubs) > 1
+	return &merged
+}
+
+// subtractAllocation takes a lapsed fill back out of the order's allocation;
+// nil when nothing is left
+func subtractAllocation(a *model.Allocation, fill []model.AllocationLine) *model.Allocation {
+	if a == nil {
+		return nil
+	}
+	rest := *a
+	rest.Lines = addAllocationLines(a.Lines, fill, -1)
+	if len(rest.Lines) == 0 {
+		return nil
+	}
+	rest.Hubs = allocationHubs(rest.Lines)
+	rest.Split = len(rest.Hubs) > 1
+	return &rest
+}
+
+// addAllocationLines sums lines per hub and SKU, dropping those that reach zero
+func addAllocationLines(lines, more []model.AllocationLine, sign int64) []model.AllocationLine {
+	out := append([]model.AllocationLine(nil), lines...)
+	for _, m := range more {
+		found := false
+		for i := range out {
+			if out[i].HubCode == m.HubCode && out[i].SKUID == m.SKUID {
+				out[i].Quantity += sign * m.Quantity
+				found = true
+				break
+			}
+		}
+		if !found && sign > 0 {
+			out = append(out, m)
+		}
+	}
+
+	kept := out[:0]
+	for _, l := range out {
+		if l.Quantity > 0 {
+			kept = append(kept, l)
+		}
+	}
+	return kept
+}
+
+// allocationHubs lists the hubs of lines in first-seen order
+func allocationHubs(lines []model.AllocationLine) []string {
+	var hubs []string
+	seen := map[string]bool{}
+	for _, l := range lines {
+		if !seen[l.HubCode] {
+			seen[l.HubCode] = true
+			hubs = append(hubs, l.HubCode)
+		}
+	}
+	return hubs
+}
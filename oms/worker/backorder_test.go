@@ -0,0 +1,107 @@
+package worker
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dhruv/oms/model"
+)
+
+func TestApplyFill(t *testing.T) {
+	lines := []model.OrderLine{
+		{SKUID: "A", Quantity: 5, Allocated: 2, Backordered: 3},
+		{SKUID: "B", Quantity: 4},
+	}
+
+	tests := []struct {
+		name string
+		fill []model.AllocationLine
+		sign int64
+		want []model.OrderLine
+	}{
+		{
+			name: "fill adds units",
+			fill: []model.AllocationLine{{HubCode: "H1", SKUID: "A", Quantity: 3}, {HubCode: "H2", SKUID: "B", Quantity: 1}},
+			sign: 1,
+			want: []model.OrderLine{
+				{SKUID: "A", Quantity: 5, Allocated: 5, Backordered: 0},
+				{SKUID: "B", Quantity: 4, Allocated: 1, Backordered: 3},
+			},
+		},
+		{
+			name: "lapsed fill takes units back",
+			fill: []model.AllocationLine{{HubCode: "H1", SKUID: "A", Quantity: 2}},
+			sign: -1,
+			want: []model.OrderLine{
+				{SKUID: "A", Quantity: 5, Allocated: 0, Backordered: 5},
+				{SKUID: "B", Quantity: 4, Allocated: 0, Backordered: 4},
+			},
+		},
+		{
+			name: "same SKU from two hubs",
+			fill: []model.AllocationLine{{HubCode: "H1", SKUID: "B", Quantity: 1}, {HubCode: "H2", SKUID: "B", Quantity: 2}},
+			sign: 1,
+			want: []model.OrderLine{
+				{SKUID: "A", Quantity: 5, Allocated: 2, Backordered: 3},
+				{SKUID: "B", Quantity: 4, Allocated: 3, Backordered: 1},
+			},
+		},
+		{
+			name: "SKU not on the order is ignored",
+			fill: []model.AllocationLine{{HubCode: "H1", SKUID: "Z", Quantity: 9}},
+			sign: 1,
+			want: []model.OrderLine{
+				{SKUID: "A", Quantity: 5, Allocated: 2, Backordered: 3},
+				{SKUID: "B", Quantity: 4, Allocated: 0, Backordered: 4},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := applyFill(lines, tt.fill, tt.sign); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if lines[0].Allocated != 2 || lines[1].Allocated != 0 {
+		t.Fatalf("applyFill changed its input: %+v", lines)
+	}
+}
+
+func TestFillStatus(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []model.OrderLine
+		want  string
+	}{
+		{"all allocated", []model.OrderLine{{Quantity: 2, Allocated: 2}, {Quantity: 1, Allocated: 1}}, model.OrderStatusNewOrder},
+		{"none allocated", []model.OrderLine{{Quantity: 2, Backordered: 2}, {Quantity: 1, Backordered: 1}}, model.OrderStatusOnHold},
+		{"one line short", []model.OrderLine{{Quantity: 2, Allocated: 2}, {Quantity: 1, Backordered: 1}}, model.OrderStatusPartiallyAllocated},
+		{"part of a line", []model.OrderLine{{Quantity: 3, Allocated: 1, Backordered: 2}}, model.OrderStatusPartiallyAllocated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fillStatus(tt.lines); got != tt.want {
+				t.Fatalf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubtractAllocation(t *testing.T) {
+	a := &model.Allocation{
+		Strategy: model.AllocationSplit,
+		Hubs:     []string{"H1", "H2"},
+		Split:    true,
+		Lines:    []model.AllocationLine{{HubCode: "H1", SKUID: "A", Quantity: 2}, {HubCode: "H2", SKUID: "B", Quantity: 1}},
+	}
+
+	rest := subtractAllocation(a, []model.AllocationLine{{HubCode: "H2", SKUID: "B", Quantity: 1}})
+	if rest == nil || rest.Split || !reflect.DeepEqual(rest.Hubs, []string{"H1"}) || len(rest.Lines) != 1 {
+		t.Fatalf("got %+v, want only H1's line left", rest)
+	}
+	if rest := subtractAllocation(rest, rest.Lines); rest != nil {
+		t.Fatalf("got %+v, want nil once every line is taken back", rest)
+	}
+}
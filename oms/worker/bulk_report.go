@@ -0,0 +1,136 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"time"
+
+	awsV2 "github.com/aws/aws-sdk-go-v2/aws"
+	awss3 "github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/omniful/go_commons/log"
+
+	"github.com/dhruv/oms/client"
+)
+
+// finishBulkJob merges the per-batch report parts of a fully processed file
+// into its error and duplicates reports and completes the job
+func finishBulkJob(ctx context.Context, s3Client *client.S3Client, evt bulkFileEvent, header []string) error {
+	logger := log.DefaultLogger()
+
+	job, err := client.GetBulkJob(ctx, evt.JobID)
+	if err != nil {
+		return err
+	}
+
+	var errKey, dupKey string
+	if len(job.ErrorParts) > 0 {
+		errKey = fmt.Sprintf("errors/%s-%d.csv", path.Base(evt.Key), time.Now().Unix())
+		errHeader := append(append([]string(nil), header...), "error_code", "error_message")
+		if err := mergeReportParts(ctx, s3Client, evt.Bucket, errKey, errHeader, job.ErrorParts); err != nil {
+			return err
+		}
+		logger.Warnf(" Bulk job %s rejected %d rows, report at s3://%s/%s", job.ID, job.Invalid, evt.Bucket, errKey)
+	}
+	if len(job.DuplicateParts) > 0 {
+		dupKey = fmt.Sprintf("duplicates/%s-%d.csv", path.Base(evt.Key), time.Now().Unix())
+		dupHeader := append(append([]string(nil), header...), "existing_order_id")
+		if err := mergeReportParts(ctx, s3Client, evt.Bucket, dupKey, dupHeader, job.DuplicateParts); err != nil {
+			return err
+		}
+		logger.Warnf(" Bulk job %s skipped %d rows already imported, report at s3://%s/%s", job.ID, job.Duplicates, evt.Bucket, dupKey)
+	}
+
+	if err := client.FinishBulkJob(ctx, job.ID, errKey, dupKey); err != nil {
+		return err
+	}
+	logger.Infof(" Bulk job %s completed: %d rows, %d orders created", job.ID, job.Total, job.OrdersCreated)
+
+	// the parts are only dropped once the job no longer points at them
+	for _, part := range append(job.ErrorParts, job.DuplicateParts...) {
+		if _, err := s3Client.Client.DeleteObject(ctx, &awss3.DeleteObjectInput{
+			Bucket: awsV2.String(evt.Bucket),
+			Key:    awsV2.String(part),
+		}); err != nil {
+			logger.Warnf(" Failed to delete report part %s: %v", part, err)
+		}
+	}
+	return nil
+}
+
+// mergeReportParts writes header followed by every part, in order, to key.
+// The parts are spooled through a temp file so a large report is never held
+// in memory.
+func mergeReportParts(ctx context.Context, s3Client *client.S3Client, bucket, key string, header []string, parts []string) error {
+	f, err := os.CreateTemp("", "bulk-report-*.csv")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	w.Write(header)
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+
+	for _, part := range parts {
+		out, err := s3Client.Client.GetObject(ctx, &awss3.GetObjectInput{
+			Bucket: awsV2.String(bucket),
+			Key:    awsV2.String(part),
+		})
+		if err != nil {
+			return fmt.Errorf("get report part %s: %w", part, err)
+		}
+		_, err = io.Copy(f, out.Body)
+		out.Body.Close()
+		if err != nil {
+			return fmt.Errorf("copy report part %s: %w", part, err)
+		}
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := s3Client.Client.PutObject(ctx, &awss3.PutObjectInput{
+		Bucket: awsV2.String(bucket),
+		Key:    awsV2.String(key),
+		Body:   f,
+	}); err != nil {
+		log.DefaultLogger().Errorf(" Failed to upload report %s: %v", key, err)
+		return err
+	}
+	return nil
+}
+
+// uploadRowsReport writes rows, preceded by header if there is one, as a CSV
+// object to bucket/key
+func uploadRowsReport(ctx context.Context, s3Client *client.S3Client, bucket, key string, header []string, rows [][]string) error {
+	logger := log.DefaultLogger()
+
+	buf := &bytes.Buffer{}
+	w := csv.NewWriter(buf)
+	if header != nil {
+		w.Write(header)
+	}
+	w.WriteAll(rows)
+	w.Flush()
+
+	_, err := s3Client.Client.PutObject(ctx, &awss3.PutObjectInput{
+		Bucket: awsV2.String(bucket),
+		Key:    awsV2.String(key),
+		Body:   bytes.NewReader(buf.Bytes()),
+	})
+	if err != nil {
+		logger.Errorf(" Failed to upload report %s: %v", key, err)
+		return err
+	}
+	logger.Infof(" Report saved to: s3://%s/%s", bucket, key)
+	return nil
+}
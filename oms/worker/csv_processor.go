@@ -10,7 +10,7 @@ import (
 	"github.com/dhruv/oms/client"
 )
 
-func StartCSVProcessor(ctx context.Context, imsClient *client.IMSClient) {
+func StartCSVProcessor(ctx context.Context, imsClient *client.IMSClient, sqsClient *client.SQSClient) {
 	logger := log.DefaultLogger()
 
 	queueURL := config.GetString(ctx, "sqs.bulk_order_queue_url")
@@ -29,7 +29,7 @@ func StartCSVProcessor(ctx context.Context, imsClient *client.IMSClient) {
 		logger.Panicf(" Failed to create SQS queue: %v", err)
 	}
 
-	handler := NewQueueHandler(imsClient)
+	handler := NewQueueHandler(imsClient, sqsClient)
 
 	consumer, err := sqs.NewConsumer(
 		qObj,
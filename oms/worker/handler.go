@@ -1,17 +1,18 @@
 package worker
 
 import (
-	"bytes"
 	"context"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"path"
+	"io"
 	"strconv"
 	"time"
 
 	awsV2 "github.com/aws/aws-sdk-go-v2/aws"
 	awss3 "github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/omniful/go_commons/config"
 	commoncsv "github.com/omniful/go_commons/csv"
 	"github.com/omniful/go_commons/log"
 	"github.com/omniful/go_commons/sqs"
@@ -20,19 +21,109 @@ import (
 	"github.com/dhruv/oms/model"
 )
 
+const defaultBulkBatchSize = 1000
+
 type queueHandler struct {
 	IMS *client.IMSClient
+	SQS *client.SQSClient // keeps a message in flight while its file is processed
+}
+
+// Error codes written to the error_code column of the error report
+const (
+	errCodeMissingColumns = "missing_columns"
+	errCodeTenantMismatch = "tenant_mismatch"
+	errCodeHubMismatch    = "hub_mismatch"
+	errCodeBadQuantity    = "bad_quantity"
+	errCodeUnknownSKU     = "unknown_sku"
+	errCodeUnknownHub     = "unknown_hub"
+	errCodeIMSUnavailable = "ims_unavailable"
+	errCodeOrderRejected  = "order_rejected"
+	errCodeOrderSplit     = "order_split"
+	errCodeSaveFailed     = "save_failed"
+)
+
+// bulkFileEvent is the SQS message body for one bulk CSV file
+type bulkFileEvent struct {
+	Bucket string `json:"Bucket"`
+	Key    string `json:"Key"`
+	JobID  string `json:"JobID"` // empty for messages queued before job tracking
+}
+
+// rowError says why a CSV row was rejected
+type rowError struct {
+	code    string
+	message string
+}
+
+// orderGroup collects the CSV rows that make up one order
+type orderGroup struct {
+	order   *model.Order
+	rows    [][]string
+	errs    []rowError // parallel to rows; zero for rows that were fine
+	invalid bool
+}
+
+// reject marks the most recently added row as the reason the order fails
+func (g *orderGroup) reject(code, message string) {
+	g.errs[len(g.errs)-1] = rowError{code: code, message: message}
+	g.invalid = true
+}
+
+// rejectedRows returns every row of the group with its error columns. Rows
+// that were fine themselves are rejected along with the rest of their order.
+func (g *orderGroup) rejectedRows(fallback rowError) [][]string {
+	out := make([][]string, 0, len(g.rows))
+	for i, row := range g.rows {
+		e := g.errs[i]
+		if e.code == "" {
+			e = fallback
+		}
+		out = append(out, withError(row, e))
+	}
+	return out
+}
+
+// splitError rejects a row that returns to an order_ref after other rows
+func splitError(orderRef string) rowError {
+	return rowError{
+		code:    errCodeOrderSplit,
+		message: fmt.Sprintf("rows of order_ref %s must be adjacent", orderRef),
+	}
+}
+
+// withError copies row with the error_code and error_message columns appended
+func withError(row []string, e rowError) []string {
+	return append(append([]string(nil), row...), e.code, e.message)
+}
+
+// addLine appends a line, merging repeated SKUs into one line
+func (g *orderGroup) addLine(skuID string, qty int64) {
+	for i := range g.order.Lines {
+		if g.order.Lines[i].SKUID == skuID {
+			g.order.Lines[i].Quantity += qty
+			return
+		}
+	}
+	g.order.Lines = append(g.order.Lines, model.OrderLine{SKUID: skuID, Quantity: qty})
 }
 
-func NewQueueHandler(ims *client.IMSClient) *queueHandler {
+// batchResult is the outcome of one batch of rows
+type batchResult struct {
+	invalid    [][]string // rows with error_code and error_message appended
+	duplicates [][]string // rows with existing_order_id appended
+	progress   model.BulkJobProgress
+}
+
+func NewQueueHandler(ims *client.IMSClient, sqsClient *client.SQSClient) *queueHandler {
 	return &queueHandler{
 		IMS: ims,
+		SQS: sqsClient,
 	}
 }
 
 func (h *queueHandler) Process(ctx context.Context, msgs *[]sqs.Message) (err error) {
 	logger := log.DefaultLogger()
-	
+
 	defer func() {
 		if r := recover(); r != nil {
 			logger.Errorf(" Recovered from panic in Process: %v", r)
@@ -49,10 +140,7 @@ func (h *queueHandler) Process(ctx context.Context, msgs *[]sqs.Message) (err er
 	for _, msg := range *msgs {
 		logger.Infof(" Processing SQS message: %s", string(msg.Value))
 
-		var evt struct {
-			Bucket string `json:"Bucket"`
-			Key    string `json:"Key"`
-		}
+		var evt bulkFileEvent
 		if err := json.Unmarshal(msg.Value, &evt); err != nil {
 			logger.Errorf(" Invalid SQS JSON: %v", err)
 			continue
@@ -62,136 +150,544 @@ func (h *queueHandler) Process(ctx context.Context, msgs *[]sqs.Message) (err er
 			continue
 		}
 
-		logger.Infof(" Fetching file from S3: %s/%s", evt.Bucket, evt.Key)
+		// A failed step leaves the message for redelivery, which resumes
+		// from the job's last checkpoint.
+		stop := h.keepInFlight(ctx, msg)
+		err := h.processFile(ctx, s3Client, evt)
+		stop()
+		if err != nil {
+			logger.Errorf(" Failed to process %s/%s, will resume on redelivery: %v", evt.Bucket, evt.Key, err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// keepInFlight extends the visibility of msg every half
+// sqs.consumer.visibility_timeout until the returned stop is called, so a
+// long file is not handed to another consumer while it is still processed
+func (h *queueHandler) keepInFlight(ctx context.Context, msg sqs.Message) (stop func()) {
+	timeout := time.Duration(config.GetInt(ctx, "sqs.consumer.visibility_timeout")) * time.Second
+	if h.SQS == nil || msg.ReceiptHandle == "" || timeout < 2*time.Second {
+		return func() {}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(timeout / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := h.SQS.ExtendVisibility(ctx, msg.ReceiptHandle, timeout); err != nil {
+					log.DefaultLogger().Warnf(" Failed to extend message visibility: %v", err)
+				}
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// processFile streams one CSV file in batches of bulk.batch_size rows and
+// checkpoints the bulk job after every batch, while keepInFlight holds the
+// message. Problems with the file itself fail the job and return nil;
+// returned errors are worth a redelivery.
+func (h *queueHandler) processFile(ctx context.Context, s3Client *client.S3Client, evt bulkFileEvent) error {
+	logger := log.DefaultLogger()
+
+	job, err := h.loadJob(ctx, evt)
+	if err != nil {
+		return err
+	}
+	if job.State == model.BulkJobCompleted || job.State == model.BulkJobFailed {
+		logger.Infof(" Bulk job %s is already %s, ignoring message", job.ID, job.State)
+		return nil
+	}
+	evt.JobID = job.ID
+
+	if err := client.StartBulkJob(ctx, job.ID); err != nil {
+		return err
+	}
+	failJob := func(reason string) {
+		if err := client.FailBulkJob(ctx, job.ID, reason); err != nil {
+			logger.Errorf(" Failed to mark bulk job %s failed: %v", job.ID, err)
+		}
+	}
+
+	logger.Infof(" Fetching file from S3: %s/%s", evt.Bucket, evt.Key)
+
+	out, err := s3Client.Client.GetObject(ctx, &awss3.GetObjectInput{
+		Bucket: awsV2.String(evt.Bucket),
+		Key:    awsV2.String(evt.Key),
+	})
+	if err != nil {
+		logger.Errorf(" Failed to get S3 object: %v", err)
+		failJob("failed to fetch file")
+		return nil
+	}
+	defer out.Body.Close()
+
+	r := csv.NewReader(out.Body)
+	r.Comma = commoncsv.CsvDelimiter
+	r.LazyQuotes = true
+	// short rows are reported as missing_columns rather than failing the file
+	r.FieldsPerRecord = -1
+
+	header, err := r.Read()
+	if err != nil {
+		logger.Errorf(" Failed to read header: %v", err)
+		failJob("failed to read CSV header")
+		return nil
+	}
+	logger.Infof(" CSV header read: %v", header)
+
+	idx := make(map[string]int)
+	for i, col := range header {
+		idx[col] = i
+	}
+	logger.Infof(" CSV column index map: %+v", idx)
+
+	stream := &rowStream{r: r}
+	if job.CheckpointRow > 0 {
+		logger.Infof(" Resuming bulk job %s after row %d", job.ID, job.CheckpointRow)
+		if err := stream.skip(job.CheckpointRow); err != nil {
+			logger.Errorf(" Failed to skip to row %d: %v", job.CheckpointRow, err)
+			failJob("failed to read CSV rows")
+			return nil
+		}
+	}
+
+	batchSize := config.GetInt(ctx, "bulk.batch_size")
+	if batchSize <= 0 {
+		batchSize = defaultBulkBatchSize
+	}
+
+	keyOf := func(row *csvRow) string { return orderKey(evt, idx, row) }
+	checkpoint := job.CheckpointRow
 
-		out, err := s3Client.Client.GetObject(ctx, &awss3.GetObjectInput{
-			Bucket: awsV2.String(evt.Bucket),
-			Key:    awsV2.String(evt.Key),
-		})
+	for {
+		batch, err := stream.nextBatch(batchSize, keyOf)
 		if err != nil {
-			logger.Errorf(" Failed to get S3 object: %v", err)
-			continue
+			logger.Errorf(" Failed to read CSV rows: %v", err)
+			failJob("failed to read CSV rows")
+			return nil
+		}
+		if len(batch) == 0 {
+			break
 		}
-		defer out.Body.Close()
 
-		r := csv.NewReader(out.Body)
-		r.Comma = commoncsv.CsvDelimiter
-		r.LazyQuotes = true
+		res := h.processBatch(ctx, evt, job.TenantID, header, idx, batch)
+
+		var errPart, dupPart string
+		if len(res.invalid) > 0 {
+			errPart = fmt.Sprintf("bulk/%s/errors/%09d.csv", job.ID, checkpoint)
+			if err := uploadRowsReport(ctx, s3Client, evt.Bucket, errPart, nil, res.invalid); err != nil {
+				return err
+			}
+		}
+		if len(res.duplicates) > 0 {
+			dupPart = fmt.Sprintf("bulk/%s/duplicates/%09d.csv", job.ID, checkpoint)
+			if err := uploadRowsReport(ctx, s3Client, evt.Bucket, dupPart, nil, res.duplicates); err != nil {
+				return err
+			}
+		}
 
-		header, err := r.Read()
+		next := checkpoint + len(batch)
+		err = client.CheckpointBulkJob(ctx, job.ID, checkpoint, next, res.progress, errPart, dupPart)
+		if errors.Is(err, client.ErrCheckpointMoved) {
+			logger.Warnf(" Bulk job %s was checkpointed past row %d by another consumer, stopping", job.ID, checkpoint)
+			return nil
+		}
 		if err != nil {
-			logger.Errorf(" Failed to read header: %v", err)
+			return err
+		}
+		checkpoint = next
+		logger.Infof(" Bulk job %s checkpointed at row %d", job.ID, checkpoint)
+	}
+
+	return finishBulkJob(ctx, s3Client, evt, header)
+}
+
+// loadJob returns the message's bulk job, creating one for messages queued
+// before jobs were tracked
+func (h *queueHandler) loadJob(ctx context.Context, evt bulkFileEvent) (*model.BulkJob, error) {
+	if evt.JobID != "" {
+		return client.GetBulkJob(ctx, evt.JobID)
+	}
+
+	job := &model.BulkJob{Bucket: evt.Bucket, FileKey: evt.Key}
+	if err := client.CreateBulkJob(ctx, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// processBatch validates a batch of rows, groups them into orders and saves
+// every valid order. When the job was uploaded by a tenant, rows of any other
+// tenant are rejected.
+//
+// The rows of an order_ref must be adjacent, in a batch as across batches
+// and redeliveries: a row that comes back to an order_ref after other rows
+// is rejected as order_split.
+func (h *queueHandler) processBatch(ctx context.Context, evt bulkFileEvent, jobTenant string, header []string, idx map[string]int, batch []*csvRow) batchResult {
+	logger := log.DefaultLogger()
+	var res batchResult
+
+	known := h.validateBatchCodes(ctx, jobTenant, idx, batch)
+
+	// Rows sharing an order_ref are grouped into one order; rows without
+	// one become single-line orders as before.
+	groups := make(map[string]*orderGroup)
+	var groupOrder []string
+	lastKey := ""
+
+	for _, cr := range batch {
+		rowNum, row := cr.num, cr.fields
+		logger.Debugf(" Row %d content dump: %#v", rowNum, row)
+
+		if len(row) < len(header) {
+			logger.Warnf(" Row %d has insufficient columns: %v", rowNum, row)
+			res.invalid = append(res.invalid, withError(row, rowError{
+				code:    errCodeMissingColumns,
+				message: fmt.Sprintf("expected %d columns, got %d", len(header), len(row)),
+			}))
 			continue
 		}
-		logger.Infof(" CSV header read: %v", header)
 
-		rows, err := r.ReadAll()
-		if err != nil {
-			logger.Errorf(" Failed to read CSV rows: %v", err)
+		getVal := func(col string) string {
+			i, ok := idx[col]
+			if !ok || i >= len(row) {
+				logger.Warnf(" Missing or invalid index for column '%s' at row %d", col, rowNum)
+				return ""
+			}
+			return row[i]
+		}
+
+		tenantID := getVal("tenant_id")
+		sellerID := getVal("seller_id")
+		hubID := getVal("hub_id")
+		orderRef := column(idx, row, "order_ref")
+
+		if jobTenant != "" && tenantID != jobTenant {
+			logger.Warnf(" Row %d belongs to tenant %s, not to the uploading tenant %s", rowNum, tenantID, jobTenant)
+			res.invalid = append(res.invalid, withError(row, rowError{
+				code:    errCodeTenantMismatch,
+				message: fmt.Sprintf("tenant %s does not match the uploading tenant %s", tenantID, jobTenant),
+			}))
+			continue
+		}
+
+		key, previous := orderKey(evt, idx, cr), lastKey
+		lastKey = key
+		g, ok := groups[key]
+		if ok && key != previous {
+			logger.Warnf(" Row %d returns to order_ref %s after other rows", rowNum, orderRef)
+			res.invalid = append(res.invalid, withError(row, splitError(orderRef)))
+			continue
+		}
+		if !ok {
+			g = &orderGroup{
+				order: &model.Order{
+					OrderRef:       orderRef,
+					IdempotencyKey: key,
+					BulkJobID:      evt.JobID,
+					SourceRow:      rowNum,
+					TenantID:       tenantID,
+					SellerID:       sellerID,
+					HubID:          hubID,
+				},
+			}
+			groups[key] = g
+			groupOrder = append(groupOrder, key)
+		}
+		g.rows = append(g.rows, row)
+		g.errs = append(g.errs, rowError{})
+
+		if g.invalid {
+			continue
+		}
+
+		if hubID != g.order.HubID {
+			logger.Warnf(" Hub mismatch within order_ref %s at row %d: %s != %s", orderRef, rowNum, hubID, g.order.HubID)
+			g.reject(errCodeHubMismatch, fmt.Sprintf("hub %s differs from hub %s of order_ref %s", hubID, g.order.HubID, orderRef))
 			continue
 		}
-		logger.Infof(" CSV rows count: %d", len(rows))
 
-		idx := make(map[string]int)
-		for i, col := range header {
-			idx[col] = i
+		qtyStr := getVal("quantity")
+		qty, err := strconv.Atoi(qtyStr)
+		if err != nil || qty <= 0 {
+			logger.Warnf(" Invalid quantity at row %d: %s", rowNum, qtyStr)
+			g.reject(errCodeBadQuantity, fmt.Sprintf("quantity %q is not a positive integer", qtyStr))
+			continue
 		}
-		logger.Infof(" CSV column index map: %+v", idx)
 
-		var invalid [][]string
+		skuID := getVal("sku_id")
+
+		codes := known[codeScope{tenantID: tenantID, sellerID: sellerID}]
+		if codes == nil {
+			logger.Errorf(" No IMS validation for tenant %s seller %s at row %d", tenantID, sellerID, rowNum)
+			g.reject(errCodeIMSUnavailable, "SKU and hub could not be validated")
+			continue
+		}
 
-		for rowNum, row := range rows {
-			fmt.Printf(" Row %d: %v\n", rowNum+1, row) 
-			logger.Infof(" Row %d only number log", rowNum+1)
-			logger.Debugf(" Row content dump: %#v", row)
+		isValidSKU := codes.skus[skuID]
+		isValidHub := codes.hubs[hubID]
 
-			if len(row) < len(header) {
-				logger.Warnf(" Row %d has insufficient columns: %v", rowNum+1, row)
-				invalid = append(invalid, row)
-				continue
+		if !isValidSKU || !isValidHub {
+			logger.Warnf(" Invalid SKU or Hub at row %d: SKU=%s Hub=%s", rowNum, skuID, hubID)
+			if !isValidSKU {
+				g.reject(errCodeUnknownSKU, fmt.Sprintf("SKU %s does not exist", skuID))
+			} else {
+				g.reject(errCodeUnknownHub, fmt.Sprintf("hub %s does not exist", hubID))
 			}
+			continue
+		}
+
+		g.addLine(skuID, int64(qty))
+	}
+
+	for _, key := range groupOrder {
+		g := groups[key]
+		if g.invalid {
+			// one bad line rejects the whole order
+			res.invalid = append(res.invalid, g.rejectedRows(rowError{
+				code:    errCodeOrderRejected,
+				message: "another row of the same order was rejected",
+			})...)
+			continue
+		}
 
-			getVal := func(col string) string {
-				i, ok := idx[col]
-				if !ok || i >= len(row) {
-					logger.Warnf(" Missing or invalid index for column '%s' at row %d", col, rowNum+1)
-					return ""
+		order := g.order
+		logger.Debugf(" Attempting to save order: %+v", order)
+		err := client.SaveOrder(ctx, order)
+		if errors.Is(err, client.ErrDuplicateOrder) {
+			existingID := ""
+			if existing, err := client.GetOrderByIdempotencyKey(ctx, key); err == nil {
+				existingID = existing.ID
+				if existing.BulkJobID == evt.JobID && existing.AnnouncedAt == nil {
+					// saved by a delivery that died before announcing it
+					announceOrder(ctx, existing)
+				}
+				if existing.BulkJobID == evt.JobID && existing.SourceRow < batch[0].num {
+					// an earlier batch of this job saved the order_ref's
+					// first rows; these come back to it after other rows
+					logger.Warnf(" Rows of order_ref %s reappear after its order %s was saved", g.order.OrderRef, existingID)
+					for _, row := range g.rows {
+						res.invalid = append(res.invalid, withError(row, splitError(g.order.OrderRef)))
+					}
+					continue
 				}
-				return row[i]
 			}
-
-			qtyStr := getVal("quantity")
-			qty, err := strconv.Atoi(qtyStr)
-			if err != nil || qty <= 0 {
-				logger.Warnf(" Invalid quantity at row %d: %s", rowNum+1, qtyStr)
-				invalid = append(invalid, row)
-				continue
+			logger.Infof(" Skipping %d rows already imported as order %s (key %s)", len(g.rows), existingID, key)
+			for _, row := range g.rows {
+				res.duplicates = append(res.duplicates, append(append([]string(nil), row...), existingID))
 			}
+			res.progress.Valid += len(g.rows)
+			res.progress.Duplicates += len(g.rows)
+			continue
+		}
+		if err != nil {
+			logger.Errorf(" Failed to save order %s: %v", key, err)
+			res.invalid = append(res.invalid, g.rejectedRows(rowError{
+				code:    errCodeSaveFailed,
+				message: "order could not be saved",
+			})...)
+			continue
+		}
+		res.progress.Valid += len(g.rows)
+		res.progress.Persisted += len(g.rows)
+		res.progress.OrdersCreated++
+		logger.Infof(" Order processed with %d lines: %+v", len(order.Lines), order)
+		announceOrder(ctx, order)
+	}
 
-			skuID := getVal("sku_id")
-			hubID := getVal("hub_id")
+	res.progress.Invalid = len(res.invalid)
+	return res
+}
 
-			if h.IMS == nil {
-				logger.Errorf(" IMS client is nil at row %d", rowNum+1)
-				invalid = append(invalid, row)
-				continue
-			}
+// announceOrder publishes order.created and fires its webhooks, then marks
+// the order announced. An order whose announcement did not go out is
+// announced again when its file is redelivered.
+func announceOrder(ctx context.Context, order *model.Order) {
+	if err := client.PublishOrderCreated(ctx, order); err != nil {
+		return
+	}
+	client.NotifyWebhooks(ctx, order.TenantID, "order.created", order)
+	if err := client.MarkOrderAnnounced(ctx, order.ID, time.Now().UTC()); err != nil {
+		log.DefaultLogger().Errorf(" Failed to mark order %s announced: %v", order.ID, err)
+	}
+}
 
-			logger.Debugf(" Calling CheckSKU on %s", skuID)
-			isValidSKU := h.IMS.CheckSKU(ctx, skuID)
-			logger.Debugf(" CheckSKU(%s) -> %v", skuID, isValidSKU)
+// codeScope is the tenant and seller that SKU and hub codes belong to
+type codeScope struct {
+	tenantID string
+	sellerID string
+}
 
-			logger.Debugf(" Calling CheckHub on %s", hubID)
-			isValidHub := h.IMS.CheckHub(ctx, hubID)
-			logger.Debugf(" CheckHub(%s) -> %v", hubID, isValidHub)
+// scopeCodes holds the SKU and hub codes IMS confirmed for one scope
+type scopeCodes struct {
+	skus map[string]bool
+	hubs map[string]bool
+}
 
-			if !isValidSKU || !isValidHub {
-				logger.Warnf(" Invalid SKU or Hub at row %d: SKU=%s Hub=%s", rowNum+1, skuID, hubID)
-				invalid = append(invalid, row)
-				continue
-			}
+// validateBatchCodes asks IMS once per tenant and seller in the batch which
+// of its SKU and hub codes exist, instead of two calls per row. Scopes IMS
+// could not answer for are left out of the result.
+func (h *queueHandler) validateBatchCodes(ctx context.Context, jobTenant string, idx map[string]int, batch []*csvRow) map[codeScope]*scopeCodes {
+	logger := log.DefaultLogger()
+	known := make(map[codeScope]*scopeCodes)
+	if h.IMS == nil {
+		logger.Errorf(" IMS client is nil, cannot validate batch")
+		return known
+	}
 
-			order := &model.Order{
-				TenantID: getVal("tenant_id"),
-				SellerID: getVal("seller_id"),
-				HubID:    hubID,
-				SKUID:    skuID,
-				Quantity: int64(qty),
+	type wanted struct {
+		skus, hubs []string
+		seen       map[string]bool
+	}
+	scopes := make(map[codeScope]*wanted)
+	var scopeOrder []codeScope
+	for _, row := range batch {
+		scope := codeScope{tenantID: column(idx, row.fields, "tenant_id"), sellerID: column(idx, row.fields, "seller_id")}
+		if jobTenant != "" && scope.tenantID != jobTenant {
+			continue
+		}
+		w, ok := scopes[scope]
+		if !ok {
+			w = &wanted{seen: make(map[string]bool)}
+			scopes[scope] = w
+			scopeOrder = append(scopeOrder, scope)
+		}
+		if sku := column(idx, row.fields, "sku_id"); sku != "" && !w.seen["sku:"+sku] {
+			w.seen["sku:"+sku] = true
+			w.skus = append(w.skus, sku)
+		}
+		if hub := column(idx, row.fields, "hub_id"); hub != "" && !w.seen["hub:"+hub] {
+			w.seen["hub:"+hub] = true
+			w.hubs = append(w.hubs, hub)
+		}
+	}
+
+	for _, scope := range scopeOrder {
+		w := scopes[scope]
+		codes := &scopeCodes{skus: map[string]bool{}, hubs: map[string]bool{}}
+		var err error
+		if len(w.skus) > 0 {
+			if codes.skus, err = h.IMS.ValidateSKUs(ctx, scope.tenantID, scope.sellerID, w.skus); err != nil {
+				logger.Errorf(" Failed to validate SKUs for tenant %s seller %s: %v", scope.tenantID, scope.sellerID, err)
+				continue
 			}
-			logger.Debugf(" Attempting to save order: %+v", order)
-			if err := client.SaveOrder(ctx, order); err != nil {
-				logger.Errorf(" Failed to save order at row %d: %v", rowNum+1, err)
-				invalid = append(invalid, row)
+		}
+		if len(w.hubs) > 0 {
+			if codes.hubs, err = h.IMS.ValidateHubs(ctx, scope.tenantID, scope.sellerID, w.hubs); err != nil {
+				logger.Errorf(" Failed to validate hubs for tenant %s seller %s: %v", scope.tenantID, scope.sellerID, err)
 				continue
 			}
-			logger.Infof(" Order processed at row %d: %+v", rowNum+1, order)
-			client.PublishOrderCreated(ctx, order)
-
-			client.NotifyWebhooks(ctx, order.TenantID, "order.created", order)
 		}
+		known[scope] = codes
+	}
+	return known
+}
 
-		if len(invalid) > 0 {
-			logger.Warnf(" Found %d invalid rows, uploading to S3", len(invalid))
-			buf := &bytes.Buffer{}
-			w := csv.NewWriter(buf)
-			w.Write(header)
-			w.WriteAll(invalid)
-			w.Flush()
+// column returns the named column of row, or "" if the file has no such column
+func column(idx map[string]int, row []string, col string) string {
+	i, ok := idx[col]
+	if !ok || i >= len(row) {
+		return ""
+	}
+	return row[i]
+}
 
-			errKey := fmt.Sprintf("errors/%s-%d.csv", path.Base(evt.Key), time.Now().Unix())
+// orderKey groups rows into orders. It doubles as the order's idempotency
+// key, so a redelivered file maps every row back to the order it created the
+// first time.
+func orderKey(evt bulkFileEvent, idx map[string]int, row *csvRow) string {
+	if ref := column(idx, row.fields, "order_ref"); ref != "" {
+		return fmt.Sprintf("ref:%s|%s|%s", column(idx, row.fields, "tenant_id"), column(idx, row.fields, "seller_id"), ref)
+	}
+	return fmt.Sprintf("file:%s/%s#row:%d", evt.Bucket, evt.Key, row.num)
+}
 
-			_, err := s3Client.Client.PutObject(ctx, &awss3.PutObjectInput{
-				Bucket: awsV2.String(evt.Bucket),
-				Key:    awsV2.String(errKey),
-				Body:   bytes.NewReader(buf.Bytes()),
-			})
-			if err != nil {
-				logger.Errorf(" Failed to upload invalid CSV: %v", err)
-			} else {
-				logger.Infof(" Invalid rows saved to: s3://%s/%s", evt.Bucket, errKey)
-			}
+// csvRow is a data row with its 1-based position in the file
+type csvRow struct {
+	num    int
+	fields []string
+}
+
+// rowStream reads data rows one at a time with one row of lookahead
+type rowStream struct {
+	r      *csv.Reader
+	read   int
+	peeked *csvRow
+}
+
+// peek returns the next row without consuming it; io.EOF at the end
+func (s *rowStream) peek() (*csvRow, error) {
+	if s.peeked == nil {
+		fields, err := s.r.Read()
+		if err != nil {
+			return nil, err
 		}
+		s.read++
+		s.peeked = &csvRow{num: s.read, fields: fields}
 	}
+	return s.peeked, nil
+}
+
+// next consumes and returns the next row; io.EOF at the end
+func (s *rowStream) next() (*csvRow, error) {
+	row, err := s.peek()
+	s.peeked = nil
+	return row, err
+}
 
+// skip discards n rows that an earlier delivery already processed
+func (s *rowStream) skip(n int) error {
+	for i := 0; i < n; i++ {
+		if _, err := s.next(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+// nextBatch returns size rows, or whatever is left of the file. Rows that
+// continue the last row's order are pulled into the batch too, so an order
+// is never split across batches.
+func (s *rowStream) nextBatch(size int, key func(*csvRow) string) ([]*csvRow, error) {
+	batch := make([]*csvRow, 0, size)
+	for len(batch) < size {
+		row, err := s.next()
+		if errors.Is(err, io.EOF) {
+			return batch, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		batch = append(batch, row)
+	}
+
+	last := key(batch[len(batch)-1])
+	for {
+		row, err := s.peek()
+		if errors.Is(err, io.EOF) {
+			return batch, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if key(row) != last {
+			return batch, nil
+		}
+		s.peeked = nil
+		batch = append(batch, row)
+	}
+}
@@ -0,0 +1,114 @@
+package worker
+
+import (
+	"encoding/csv"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dhruv/oms/model"
+)
+
+func TestOrderGroupAddLine(t *testing.T) {
+	type add struct {
+		sku string
+		qty int64
+	}
+
+	tests := []struct {
+		name string
+		adds []add
+		want []model.OrderLine
+	}{
+		{"one line", []add{{"A", 2}}, []model.OrderLine{{SKUID: "A", Quantity: 2}}},
+		{"lines keep file order", []add{{"B", 1}, {"A", 3}}, []model.OrderLine{{SKUID: "B", Quantity: 1}, {SKUID: "A", Quantity: 3}}},
+		{"repeated SKU is merged", []add{{"A", 1}, {"B", 2}, {"A", 4}}, []model.OrderLine{{SKUID: "A", Quantity: 5}, {SKUID: "B", Quantity: 2}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &orderGroup{order: &model.Order{}}
+			for _, a := range tt.adds {
+				g.addLine(a.sku, a.qty)
+			}
+			if !reflect.DeepEqual(g.order.Lines, tt.want) {
+				t.Fatalf("lines = %+v, want %+v", g.order.Lines, tt.want)
+			}
+		})
+	}
+}
+
+func TestRowStreamNextBatch(t *testing.T) {
+	// the first column is the order key
+	file := "o1,a\no1,b\no2,c\no3,d\no3,e\no3,f\no4,g\n"
+	key := func(row *csvRow) string { return row.fields[0] }
+
+	tests := []struct {
+		name string
+		skip int
+		size int
+		want [][]int // row numbers of each batch until the end of the file
+	}{
+		{"one row per batch keeps orders whole", 0, 1, [][]int{{1, 2}, {3}, {4, 5, 6}, {7}}},
+		{"batch ends inside an order", 0, 4, [][]int{{1, 2, 3, 4, 5, 6}, {7}}},
+		{"batch ends on an order boundary", 0, 3, [][]int{{1, 2, 3}, {4, 5, 6}, {7}}},
+		{"whole file", 0, 100, [][]int{{1, 2, 3, 4, 5, 6, 7}}},
+		{"after skip", 3, 2, [][]int{{4, 5, 6}, {7}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &rowStream{r: csv.NewReader(strings.NewReader(file))}
+			if err := s.skip(tt.skip); err != nil {
+				t.Fatalf("skip: %v", err)
+			}
+
+			var got [][]int
+			for {
+				batch, err := s.nextBatch(tt.size, key)
+				if err != nil {
+					t.Fatalf("nextBatch: %v", err)
+				}
+				if len(batch) == 0 {
+					break
+				}
+				nums := make([]int, 0, len(batch))
+				for _, row := range batch {
+					nums = append(nums, row.num)
+				}
+				got = append(got, nums)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("batches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRowStreamNextBatchError(t *testing.T) {
+	// the third row has an extra field
+	s := &rowStream{r: csv.NewReader(strings.NewReader("o1,a\no1,b\no1,c,x\n"))}
+	if _, err := s.nextBatch(1, func(row *csvRow) string { return row.fields[0] }); err == nil {
+		t.Fatal("got no error for a malformed continuation row")
+	}
+}
+
+func TestOrderKey(t *testing.T) {
+	evt := bulkFileEvent{Bucket: "orders", Key: "in/file.csv"}
+	idx := map[string]int{"tenant_id": 0, "seller_id": 1, "order_ref": 2}
+
+	tests := []struct {
+		name   string
+		fields []string
+		want   string
+	}{
+		{"order_ref", []string{"t1", "s1", "R-1"}, "ref:t1|s1|R-1"},
+		{"no order_ref", []string{"t1", "s1", ""}, "file:orders/in/file.csv#row:5"},
+		{"short row", []string{"t1"}, "file:orders/in/file.csv#row:5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := orderKey(evt, idx, &csvRow{num: 5, fields: tt.fields}); got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/omniful/go_commons/config"
+	"github.com/omniful/go_commons/log"
+	"github.com/omniful/go_commons/pubsub"
+
+	"github.com/dhruv/oms/client"
+	"github.com/dhruv/oms/model"
+)
+
+const defaultOnHoldRetryBatch = 50
+
+// InventoryUpdatedHandler retries on_hold orders and fills backorders when
+// IMS reports new stock
+type InventoryUpdatedHandler struct{}
+
+func (h *InventoryUpdatedHandler) Process(ctx context.Context, msg *pubsub.Message) error {
+	logger := log.DefaultLogger()
+
+	var event model.InventoryUpdated
+	if err := json.Unmarshal(msg.Value, &event); err != nil {
+		logger.Errorf(" Failed to unmarshal InventoryUpdated: %v", err)
+		return err
+	}
+
+	logger.Infof(" Processing inventory.updated: tenant=%s hub=%s sku=%s delta=%d reason=%s",
+		event.TenantID, event.HubCode, event.SKUCode, event.Delta, event.Reason)
+
+	limit := int64(config.GetInt(ctx, "kafka.on_hold_retry_batch"))
+	if limit <= 0 {
+		limit = defaultOnHoldRetryBatch
+	}
+
+	// Unless orders are fixed to their hub, stock at any hub may fit them
+	hubID := ""
+	if allocationStrategy(ctx, event.TenantID) == model.AllocationFixed {
+		hubID = event.HubCode
+	}
+
+	orders, err := client.FindAwaitingStockOrders(ctx, event.TenantID, event.SellerID, hubID, event.SKUCode, limit)
+	if err != nil {
+		logger.Errorf(" Failed to load orders awaiting stock: %v", err)
+		return err
+	}
+
+	for i := range orders {
+		short, err := finalizeOrder(ctx, model.NewOrderCreated(&orders[i]))
+		if err != nil {
+			logger.Errorf(" Retry of %s order %s failed: %v", orders[i].Status, orders[i].ID, err)
+			return err
+		}
+
+		// Younger orders may still fit, unless this SKU has run out entirely
+		if skuExhausted(short, event.HubCode, event.SKUCode) {
+			logger.Infof(" SKU %s at hub %s exhausted after %d retries", event.SKUCode, event.HubCode, i+1)
+			break
+		}
+	}
+
+	return nil
+}
+
+func skuExhausted(short []client.ShortLine, hubCode, skuCode string) bool {
+	for _, l := range short {
+		if l.HubCode == hubCode && l.SKUCode == skuCode && l.Available == 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -3,6 +3,7 @@ package worker
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"time"
 
 	"github.com/omniful/go_commons/config"
@@ -24,8 +25,19 @@ func (h *OrderCreatedHandler) Process(ctx context.Context, msg *pubsub.Message)
 		return err
 	}
 
+	log.DefaultLogger().Infof(" Processing order.created for OrderID: %s", event.OrderID)
+
+	_, err := finalizeOrder(ctx, event)
+	return err
+}
+
+// finalizeOrder allocates the order to one or more of the seller's hubs,
+// tries to hold stock for every line there and moves the order to new_order
+// with the allocation recorded on it. If stock is short the order stays
+// on_hold and the short lines are returned with a nil error. Tenants with the
+// partial fulfillment policy go through finalizePartial instead.
+func finalizeOrder(ctx context.Context, event model.OrderCreated) ([]client.ShortLine, error) {
 	logger := log.DefaultLogger()
-	logger.Infof(" Processing order.created for OrderID: %s", event.OrderID)
 
 	baseURL := config.GetString(ctx, "ims.base_url")
 	timeout := config.GetDuration(ctx, "ims.timeout")
@@ -33,27 +45,97 @@ func (h *OrderCreatedHandler) Process(ctx context.Context, msg *pubsub.Message)
 	ctxWithTimeout, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	// Call IMS to check inventory
-	inventory, err := client.FetchInventory(ctxWithTimeout, baseURL, event.TenantID, event.SellerID, event.HubCode, event.SKUCode)
+	// A previous attempt may have held stock and moved the order already;
+	// only the commit is left to do in that case.
+	existing, err := client.GetOrderByID(ctx, event.OrderID)
 	if err != nil {
-		logger.Errorf(" IMS fetch inventory failed: %v", err)
-		return err
+		logger.Errorf(" Failed to load order %s: %v", event.OrderID, err)
+		return nil, err
 	}
-
-	if inventory.Quantity >= event.Quantity {
-		// Reduce inventory
-		if err := client.ConsumeInventory(ctxWithTimeout, baseURL, event.TenantID, event.SellerID, event.HubCode, event.SKUCode, event.Quantity); err != nil {
-			logger.Errorf(" IMS consume inventory failed: %v", err)
-			return err
+	partial := fulfillmentPolicy(ctx, event.TenantID) == model.FulfillmentPartial
+	if existing.PendingFill != nil || (partial && existing.Status != model.OrderStatusNewOrder) {
+		return finalizePartial(ctxWithTimeout, baseURL, event.OrderID)
+	}
+	if existing.Status == model.OrderStatusNewOrder && existing.ReservationID != "" {
+		if _, err := commitOrderReservation(ctxWithTimeout, baseURL, event.OrderID, existing.ReservationID); err != nil {
+			return nil, err
 		}
+		logger.Infof(" Order %s already finalized, reservation %s settled", event.OrderID, existing.ReservationID)
+		return nil, nil
+	}
+	if existing.Status != model.OrderStatusOnHold {
+		logger.Infof(" Order %s is %s, nothing to finalize", event.OrderID, existing.Status)
+		return nil, nil
+	}
+
+	allocation, short, err := allocateOrder(ctxWithTimeout, baseURL, event, false)
+	if err != nil {
+		logger.Errorf(" IMS availability lookup for order %s failed: %v", event.OrderID, err)
+		return nil, err
+	}
+	if allocation == nil {
+		logger.Warnf(" Order %s kept on_hold, no hub allocation possible: %+v", event.OrderID, short)
+		return short, nil
+	}
+	logger.Infof(" Order %s allocated (%s): %s", event.OrderID, allocation.Strategy, allocation.Reason)
+
+	// Hold every line in one IMS transaction; nothing is held if any line is short
+	lines := make([]client.ReserveLine, 0, len(allocation.Lines))
+	for _, line := range allocation.Lines {
+		lines = append(lines, client.ReserveLine{HubCode: line.HubCode, SKUCode: line.SKUID, Quantity: line.Quantity})
+	}
+
+	reservation, short, err := client.CreateReservation(ctxWithTimeout, baseURL, event.TenantID, event.SellerID, event.OrderID, config.GetDuration(ctx, "ims.reservation_ttl"), lines)
+	if errors.Is(err, client.ErrReferenceHeld) {
+		// another consumer is finalizing the order with a different allocation
+		logger.Infof(" Order %s is being finalized elsewhere: %v", event.OrderID, err)
+		return nil, nil
+	}
+	if err != nil && !errors.Is(err, client.ErrInsufficientInventory) {
+		logger.Errorf(" IMS reserve inventory failed: %v", err)
+		return nil, err
+	}
 
-		// Update order status to new_order
-		if err := client.UpdateOrderStatus(ctxWithTimeout, baseURL, client.UpdateOrderStatusRequest{
-			OrderID: event.OrderID,
-			Status:  "new_order",
+	if err == nil {
+		// Update order status to new_order, only if it is still on_hold
+		if err := client.UpdateOrderStatus(ctxWithTimeout, client.UpdateOrderStatusRequest{
+			OrderID:       event.OrderID,
+			From:          model.OrderStatusOnHold,
+			Status:        model.OrderStatusNewOrder,
+			Actor:         client.ActorFinalizer,
+			Reason:        "inventory reserved",
+			ReservationID: reservation.ID,
+			Allocation:    allocation,
 		}); err != nil {
 			logger.Errorf(" Failed to update order status: %v", err)
-			return err
+			if errors.Is(err, client.ErrIllegalTransition) {
+				// another consumer finalized or cancelled the order first. IMS
+				// hands every finalizer of the order the same open hold, so
+				// it is only ours to give back if the order does not use it.
+				current, getErr := client.GetOrderByID(ctxWithTimeout, event.OrderID)
+				if getErr != nil {
+					return nil, getErr
+				}
+				if current.ReservationID != reservation.ID {
+					if relErr := client.ReleaseReservation(ctxWithTimeout, baseURL, reservation.ID); relErr != nil {
+						logger.Errorf(" IMS release reservation %s failed: %v", reservation.ID, relErr)
+					}
+				}
+				return nil, nil
+			}
+			// give the stock back now rather than waiting for the hold to expire
+			if relErr := client.ReleaseReservation(ctxWithTimeout, baseURL, reservation.ID); relErr != nil {
+				logger.Errorf(" IMS release reservation %s failed: %v", reservation.ID, relErr)
+			}
+			return nil, err
+		}
+
+		committed, err := commitOrderReservation(ctxWithTimeout, baseURL, event.OrderID, reservation.ID)
+		if err != nil {
+			return nil, err
+		}
+		if !committed {
+			return nil, nil
 		}
 		logger.Infof(" Order %s finalized as new_order", event.OrderID)
 
@@ -67,13 +149,13 @@ func (h *OrderCreatedHandler) Process(ctx context.Context, msg *pubsub.Message)
 		coll, err := client.GetOrdersCollection(ctx)
 		if err != nil {
 			logger.Errorf(" Failed to get orders collection: %v", err)
-			return err
+			return nil, err
 		}
 
 		var fullOrder model.Order
 		if err := coll.FindOne(ctx, bson.M{"_id": event.OrderID}).Decode(&fullOrder); err != nil {
 			logger.Errorf(" Failed to load order for webhook: %v", err)
-			return err
+			return nil, err
 		}
 
 		// Trigger webhook with full order
@@ -81,18 +163,42 @@ func (h *OrderCreatedHandler) Process(ctx context.Context, msg *pubsub.Message)
 
 		//////////////////////////
 	} else {
-		// Not enough stock, keep on_hold
-		if err := client.UpdateOrderStatus(ctxWithTimeout, baseURL, client.UpdateOrderStatusRequest{
-			OrderID: event.OrderID,
-			Status:  "on_hold",
-		}); err != nil {
-			logger.Errorf(" Failed to update order status: %v", err)
-			return err
-		}
-		logger.Warnf(" Order %s kept on_hold due to insufficient inventory", event.OrderID)
+		// Not enough stock, the order stays on_hold
+		logger.Warnf(" Order %s kept on_hold due to insufficient inventory: %+v", event.OrderID, short)
+		return short, nil
 	}
 
-	return nil
+	return nil, nil
+}
+
+// commitOrderReservation commits the order's hold. If the hold expired before
+// it could be committed the stock is already back, so the order returns to
+// on_hold instead of staying new_order without stock behind it; committed is
+// false in that case.
+func commitOrderReservation(ctx context.Context, baseURL, orderID, reservationID string) (committed bool, err error) {
+	logger := log.DefaultLogger()
+
+	err = client.CommitReservation(ctx, baseURL, reservationID)
+	if errors.Is(err, client.ErrReservationClosed) {
+		logger.Warnf(" Reservation %s for order %s is no longer held, moving order back to on_hold", reservationID, orderID)
+		err = client.UpdateOrderStatus(ctx, client.UpdateOrderStatusRequest{
+			OrderID: orderID,
+			From:    model.OrderStatusNewOrder,
+			Status:  model.OrderStatusOnHold,
+			Actor:   client.ActorFinalizer,
+			Reason:  "inventory hold expired before commit",
+		})
+		if errors.Is(err, client.ErrIllegalTransition) {
+			// already moved back, or cancelled
+			return false, nil
+		}
+		return false, err
+	}
+	if err != nil {
+		logger.Errorf(" IMS commit reservation %s failed: %v", reservationID, err)
+		return false, err
+	}
+	return true, nil
 }
 
 func StartOrderFinalizer(ctx context.Context) {
@@ -111,10 +217,12 @@ func StartOrderFinalizer(ctx context.Context) {
 		kafka.WithRetryInterval(time.Second),
 	)
 
-	log.DefaultLogger().Infof(" Consumer subscribing to topic: order.created")
+	inventoryTopic := config.GetString(ctx, "kafka.inventory_topic")
+	log.DefaultLogger().Infof(" Consumer subscribing to topics: order.created, %s", inventoryTopic)
 
 	handler := &OrderCreatedHandler{}
 	consumer.RegisterHandler("order.created", handler)
+	consumer.RegisterHandler(inventoryTopic, &InventoryUpdatedHandler{})
 
 	go consumer.Subscribe(ctx)
 }